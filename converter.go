@@ -40,7 +40,7 @@ func clampUint16(in int64) uint16 {
 	return uint16(in)
 }
 
-func resizeGeneric(in image.Image, out *image.RGBA64, scale float64, coeffs []int32, offset []int, filterLength int) {
+func resizeGeneric(in image.Image, out *image.RGBA64, scale float64, coeffs []int32, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -54,11 +54,11 @@ func resizeGeneric(in image.Image, out *image.RGBA64, scale float64, coeffs []in
 				coeff := coeffs[ci+i]
 				if coeff != 0 {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = maxX
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum += int64(coeff)
+							continue
+						}
 					}
 					r, g, b, a := in.At(xi+in.Bounds().Min.X, x+in.Bounds().Min.Y).RGBA()
 					rgba[0] += int64(coeff) * int64(r)
@@ -86,7 +86,7 @@ func resizeGeneric(in image.Image, out *image.RGBA64, scale float64, coeffs []in
 	}
 }
 
-func resizeRGBA(in *image.RGBA, out *image.RGBA, scale float64, coeffs []int16, offset []int, filterLength int) {
+func resizeRGBA(in *image.RGBA, out *image.RGBA, scale float64, coeffs []int16, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -101,14 +101,13 @@ func resizeRGBA(in *image.RGBA, out *image.RGBA, scale float64, coeffs []int16,
 				coeff := coeffs[ci+i]
 				if coeff != 0 {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = 4 * maxX
-					default:
-						xi *= 4
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum += int32(coeff)
+							continue
+						}
 					}
+					xi *= 4
 					rgba[0] += int32(coeff) * int32(row[xi+0])
 					rgba[1] += int32(coeff) * int32(row[xi+1])
 					rgba[2] += int32(coeff) * int32(row[xi+2])
@@ -126,7 +125,7 @@ func resizeRGBA(in *image.RGBA, out *image.RGBA, scale float64, coeffs []int16,
 	}
 }
 
-func resizeRGBA64(in *image.RGBA64, out *image.RGBA64, scale float64, coeffs []int32, offset []int, filterLength int) {
+func resizeRGBA64(in *image.RGBA64, out *image.RGBA64, scale float64, coeffs []int32, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -141,14 +140,13 @@ func resizeRGBA64(in *image.RGBA64, out *image.RGBA64, scale float64, coeffs []i
 				coeff := coeffs[ci+i]
 				if coeff != 0 {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = 8 * maxX
-					default:
-						xi *= 8
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum += int64(coeff)
+							continue
+						}
 					}
+					xi *= 8
 					rgba[0] += int64(coeff) * int64(uint16(row[xi+0])<<8|uint16(row[xi+1]))
 					rgba[1] += int64(coeff) * int64(uint16(row[xi+2])<<8|uint16(row[xi+3]))
 					rgba[2] += int64(coeff) * int64(uint16(row[xi+4])<<8|uint16(row[xi+5]))
@@ -174,7 +172,7 @@ func resizeRGBA64(in *image.RGBA64, out *image.RGBA64, scale float64, coeffs []i
 	}
 }
 
-func resizeGray(in *image.Gray, out *image.Gray, scale float64, coeffs []int16, offset []int, filterLength int) {
+func resizeGray(in *image.Gray, out *image.Gray, scale float64, coeffs []int16, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -189,11 +187,11 @@ func resizeGray(in *image.Gray, out *image.Gray, scale float64, coeffs []int16,
 				coeff := coeffs[ci+i]
 				if coeff != 0 {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = maxX
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum += int32(coeff)
+							continue
+						}
 					}
 					gray += int32(coeff) * int32(row[xi])
 					sum += int32(coeff)
@@ -206,7 +204,7 @@ func resizeGray(in *image.Gray, out *image.Gray, scale float64, coeffs []int16,
 	}
 }
 
-func resizeGray16(in *image.Gray16, out *image.Gray16, scale float64, coeffs []int32, offset []int, filterLength int) {
+func resizeGray16(in *image.Gray16, out *image.Gray16, scale float64, coeffs []int32, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -221,14 +219,13 @@ func resizeGray16(in *image.Gray16, out *image.Gray16, scale float64, coeffs []i
 				coeff := coeffs[ci+i]
 				if coeff != 0 {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = 2 * maxX
-					default:
-						xi *= 2
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum += int64(coeff)
+							continue
+						}
 					}
+					xi *= 2
 					gray += int64(coeff) * int64(uint16(row[xi+0])<<8|uint16(row[xi+1]))
 					sum += int64(coeff)
 				}
@@ -242,77 +239,240 @@ func resizeGray16(in *image.Gray16, out *image.Gray16, scale float64, coeffs []i
 	}
 }
 
-func resizeYCbCr(in *ycc, out *ycc, scale float64, coeffs []int16, offset []int, filterLength int) {
+func nearestYCbCr(in *ycc, out *ycc, scale float64, coeffs []bool, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
 	for x := newBounds.Min.X; x < newBounds.Max.X; x++ {
 		row := in.Pix[x*in.Stride:]
 		for y := newBounds.Min.Y; y < newBounds.Max.Y; y++ {
-			var p [3]int32
-			var sum int32
+			var p [3]float32
+			var sum float32
+			start := offset[y]
+			ci := y * filterLength
+			for i := 0; i < filterLength; i++ {
+				if coeffs[ci+i] {
+					xi := start + i
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum++
+							continue
+						}
+					}
+					xi *= 3
+					p[0] += float32(row[xi+0])
+					p[1] += float32(row[xi+1])
+					p[2] += float32(row[xi+2])
+					sum++
+				}
+			}
+
+			xo := (y-newBounds.Min.Y)*out.Stride + (x-newBounds.Min.X)*3
+			out.Pix[xo+0] = floatToUint8(p[0] / sum)
+			out.Pix[xo+1] = floatToUint8(p[1] / sum)
+			out.Pix[xo+2] = floatToUint8(p[2] / sum)
+		}
+	}
+}
+
+func resizeNRGBA(in *image.NRGBA, out *image.RGBA64, scale float64, coeffs []int32, offset []int, filterLength int, edge Edge) {
+	newBounds := out.Bounds()
+	maxX := in.Bounds().Dx() - 1
+
+	for x := newBounds.Min.X; x < newBounds.Max.X; x++ {
+		row := in.Pix[x*in.Stride:]
+		for y := newBounds.Min.Y; y < newBounds.Max.Y; y++ {
+			var rgba [4]int64
+			var sum int64
 			start := offset[y]
 			ci := y * filterLength
 			for i := 0; i < filterLength; i++ {
 				coeff := coeffs[ci+i]
 				if coeff != 0 {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = 3 * maxX
-					default:
-						xi *= 3
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum += int64(coeff)
+							continue
+						}
 					}
-					p[0] += int32(coeff) * int32(row[xi+0])
-					p[1] += int32(coeff) * int32(row[xi+1])
-					p[2] += int32(coeff) * int32(row[xi+2])
-					sum += int32(coeff)
+					xi *= 4
+					// Premultiply the color samples with alpha,
+					// scaled to 16-bit.
+					a := int64(row[xi+3]) * 0x101
+					rgba[0] += int64(coeff) * ((int64(row[xi+0])*a + 0x7f) / 0xff)
+					rgba[1] += int64(coeff) * ((int64(row[xi+1])*a + 0x7f) / 0xff)
+					rgba[2] += int64(coeff) * ((int64(row[xi+2])*a + 0x7f) / 0xff)
+					rgba[3] += int64(coeff) * a
+					sum += int64(coeff)
 				}
 			}
 
-			xo := (y-newBounds.Min.Y)*out.Stride + (x-newBounds.Min.X)*3
-			out.Pix[xo+0] = clampUint8(p[0] / sum)
-			out.Pix[xo+1] = clampUint8(p[1] / sum)
-			out.Pix[xo+2] = clampUint8(p[2] / sum)
+			xo := (y-newBounds.Min.Y)*out.Stride + (x-newBounds.Min.X)*8
+			value := clampUint16(rgba[0] / sum)
+			out.Pix[xo+0] = uint8(value >> 8)
+			out.Pix[xo+1] = uint8(value)
+			value = clampUint16(rgba[1] / sum)
+			out.Pix[xo+2] = uint8(value >> 8)
+			out.Pix[xo+3] = uint8(value)
+			value = clampUint16(rgba[2] / sum)
+			out.Pix[xo+4] = uint8(value >> 8)
+			out.Pix[xo+5] = uint8(value)
+			value = clampUint16(rgba[3] / sum)
+			out.Pix[xo+6] = uint8(value >> 8)
+			out.Pix[xo+7] = uint8(value)
 		}
 	}
 }
 
-func nearestYCbCr(in *ycc, out *ycc, scale float64, coeffs []bool, offset []int, filterLength int) {
+func resizeNRGBA64(in *image.NRGBA64, out *image.RGBA64, scale float64, coeffs []int32, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
 	for x := newBounds.Min.X; x < newBounds.Max.X; x++ {
 		row := in.Pix[x*in.Stride:]
 		for y := newBounds.Min.Y; y < newBounds.Max.Y; y++ {
-			var p [3]float32
-			var sum float32
+			var rgba [4]int64
+			var sum int64
 			start := offset[y]
 			ci := y * filterLength
 			for i := 0; i < filterLength; i++ {
-				if coeffs[ci+i] {
+				coeff := coeffs[ci+i]
+				if coeff != 0 {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = 3 * maxX
-					default:
-						xi *= 3
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum += int64(coeff)
+							continue
+						}
 					}
-					p[0] += float32(row[xi+0])
-					p[1] += float32(row[xi+1])
-					p[2] += float32(row[xi+2])
-					sum++
+					xi *= 8
+					// Premultiply the color samples with alpha.
+					a := int64(uint16(row[xi+6])<<8 | uint16(row[xi+7]))
+					rgba[0] += int64(coeff) * ((int64(uint16(row[xi+0])<<8|uint16(row[xi+1]))*a + 0x7fff) / 0xffff)
+					rgba[1] += int64(coeff) * ((int64(uint16(row[xi+2])<<8|uint16(row[xi+3]))*a + 0x7fff) / 0xffff)
+					rgba[2] += int64(coeff) * ((int64(uint16(row[xi+4])<<8|uint16(row[xi+5]))*a + 0x7fff) / 0xffff)
+					rgba[3] += int64(coeff) * a
+					sum += int64(coeff)
 				}
 			}
 
-			xo := (y-newBounds.Min.Y)*out.Stride + (x-newBounds.Min.X)*3
-			out.Pix[xo+0] = floatToUint8(p[0] / sum)
-			out.Pix[xo+1] = floatToUint8(p[1] / sum)
-			out.Pix[xo+2] = floatToUint8(p[2] / sum)
+			xo := (y-newBounds.Min.Y)*out.Stride + (x-newBounds.Min.X)*8
+			value := clampUint16(rgba[0] / sum)
+			out.Pix[xo+0] = uint8(value >> 8)
+			out.Pix[xo+1] = uint8(value)
+			value = clampUint16(rgba[1] / sum)
+			out.Pix[xo+2] = uint8(value >> 8)
+			out.Pix[xo+3] = uint8(value)
+			value = clampUint16(rgba[2] / sum)
+			out.Pix[xo+4] = uint8(value >> 8)
+			out.Pix[xo+5] = uint8(value)
+			value = clampUint16(rgba[3] / sum)
+			out.Pix[xo+6] = uint8(value >> 8)
+			out.Pix[xo+7] = uint8(value)
+		}
+	}
+}
+
+// unpremultiply converts a filtered, alpha-premultiplied 16-bit color back
+// to non-premultiplied values, rounded to the nearest.
+func unpremultiply(rgba *[4]int64, sum int64) (r, g, b, a uint16) {
+	a = clampUint16(rgba[3] / sum)
+	if a == 0 {
+		return 0, 0, 0, 0
+	}
+	color := func(v int64) uint16 {
+		v = (v + sum/2) / sum
+		return clampUint16((v*0xffff + int64(a)/2) / int64(a))
+	}
+	return color(rgba[0]), color(rgba[1]), color(rgba[2]), a
+}
+
+// narrow rounds the 16-bit value v to 8 bits.
+func narrow(v uint16) uint8 {
+	return uint8((uint32(v)*0xff + 0x7fff) / 0xffff)
+}
+
+func resizeRGBA64ToNRGBA(in *image.RGBA64, out *image.NRGBA, scale float64, coeffs []int32, offset []int, filterLength int, edge Edge) {
+	newBounds := out.Bounds()
+	maxX := in.Bounds().Dx() - 1
+
+	for x := newBounds.Min.X; x < newBounds.Max.X; x++ {
+		row := in.Pix[x*in.Stride:]
+		for y := newBounds.Min.Y; y < newBounds.Max.Y; y++ {
+			var rgba [4]int64
+			var sum int64
+			start := offset[y]
+			ci := y * filterLength
+			for i := 0; i < filterLength; i++ {
+				coeff := coeffs[ci+i]
+				if coeff != 0 {
+					xi := start + i
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum += int64(coeff)
+							continue
+						}
+					}
+					xi *= 8
+					rgba[0] += int64(coeff) * int64(uint16(row[xi+0])<<8|uint16(row[xi+1]))
+					rgba[1] += int64(coeff) * int64(uint16(row[xi+2])<<8|uint16(row[xi+3]))
+					rgba[2] += int64(coeff) * int64(uint16(row[xi+4])<<8|uint16(row[xi+5]))
+					rgba[3] += int64(coeff) * int64(uint16(row[xi+6])<<8|uint16(row[xi+7]))
+					sum += int64(coeff)
+				}
+			}
+
+			xo := (y-newBounds.Min.Y)*out.Stride + (x-newBounds.Min.X)*4
+			r, g, b, a := unpremultiply(&rgba, sum)
+			out.Pix[xo+0] = narrow(r)
+			out.Pix[xo+1] = narrow(g)
+			out.Pix[xo+2] = narrow(b)
+			out.Pix[xo+3] = narrow(a)
+		}
+	}
+}
+
+func resizeRGBA64ToNRGBA64(in *image.RGBA64, out *image.NRGBA64, scale float64, coeffs []int32, offset []int, filterLength int, edge Edge) {
+	newBounds := out.Bounds()
+	maxX := in.Bounds().Dx() - 1
+
+	for x := newBounds.Min.X; x < newBounds.Max.X; x++ {
+		row := in.Pix[x*in.Stride:]
+		for y := newBounds.Min.Y; y < newBounds.Max.Y; y++ {
+			var rgba [4]int64
+			var sum int64
+			start := offset[y]
+			ci := y * filterLength
+			for i := 0; i < filterLength; i++ {
+				coeff := coeffs[ci+i]
+				if coeff != 0 {
+					xi := start + i
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum += int64(coeff)
+							continue
+						}
+					}
+					xi *= 8
+					rgba[0] += int64(coeff) * int64(uint16(row[xi+0])<<8|uint16(row[xi+1]))
+					rgba[1] += int64(coeff) * int64(uint16(row[xi+2])<<8|uint16(row[xi+3]))
+					rgba[2] += int64(coeff) * int64(uint16(row[xi+4])<<8|uint16(row[xi+5]))
+					rgba[3] += int64(coeff) * int64(uint16(row[xi+6])<<8|uint16(row[xi+7]))
+					sum += int64(coeff)
+				}
+			}
+
+			xo := (y-newBounds.Min.Y)*out.Stride + (x-newBounds.Min.X)*8
+			r, g, b, a := unpremultiply(&rgba, sum)
+			out.Pix[xo+0] = uint8(r >> 8)
+			out.Pix[xo+1] = uint8(r)
+			out.Pix[xo+2] = uint8(g >> 8)
+			out.Pix[xo+3] = uint8(g)
+			out.Pix[xo+4] = uint8(b >> 8)
+			out.Pix[xo+5] = uint8(b)
+			out.Pix[xo+6] = uint8(a >> 8)
+			out.Pix[xo+7] = uint8(a)
 		}
 	}
 }
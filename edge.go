@@ -0,0 +1,62 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+// An Edge selects the samples used by the filters outside of the image.
+type Edge int
+
+const (
+	// EdgeClamp repeats the pixels at the edges of the image.
+	EdgeClamp Edge = iota
+	// EdgeReflect mirrors the image at its edges.
+	EdgeReflect
+	// EdgeWrap repeats the image, for tiled textures.
+	EdgeWrap
+	// EdgeTransparent treats samples outside of the image as transparent,
+	// which fades the edges of the result. Images without alpha channel
+	// use EdgeClamp instead.
+	EdgeTransparent
+)
+
+// index maps the index xi outside of a row of maxX+1 samples to the
+// sample used instead, or -1 for a transparent sample.
+func (e Edge) index(xi, maxX int) int {
+	n := maxX + 1
+	switch e {
+	case EdgeReflect:
+		xi %= 2 * n
+		if xi < 0 {
+			xi += 2 * n
+		}
+		if xi >= n {
+			xi = 2*n - 1 - xi
+		}
+		return xi
+	case EdgeWrap:
+		xi %= n
+		if xi < 0 {
+			xi += n
+		}
+		return xi
+	case EdgeTransparent:
+		return -1
+	}
+	if xi < 0 {
+		return 0
+	}
+	return maxX
+}
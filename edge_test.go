@@ -0,0 +1,82 @@
+package resize
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func Test_EdgeIndex(t *testing.T) {
+	for _, c := range []struct {
+		edge Edge
+		xi   int
+		want int
+	}{
+		{EdgeClamp, -5, 0},
+		{EdgeClamp, 9, 3},
+		{EdgeReflect, -1, 0},
+		{EdgeReflect, -2, 1},
+		{EdgeReflect, 4, 3},
+		{EdgeReflect, 5, 2},
+		{EdgeReflect, 9, 1},
+		{EdgeWrap, -1, 3},
+		{EdgeWrap, 4, 0},
+		{EdgeWrap, -9, 3},
+		{EdgeTransparent, -1, -1},
+		{EdgeTransparent, 4, -1},
+	} {
+		if got := c.edge.index(c.xi, 3); got != c.want {
+			t.Errorf("edge %d, index %d: got %d, want %d", c.edge, c.xi, got, c.want)
+		}
+	}
+}
+
+func Test_EdgeWrap(t *testing.T) {
+	// White left column, the right edge of the result only gets
+	// brighter if the image is repeated.
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		img.Pix[y*img.Stride] = 0xff
+	}
+	var right [2]uint8
+	for i, edge := range []Edge{EdgeClamp, EdgeWrap} {
+		m, err := ResizeWithOptions(8, 8, img, Lanczos3, &Options{Edge: edge})
+		if err != nil {
+			t.Fatal(err)
+		}
+		right[i] = m.(*image.Gray).GrayAt(7, 4).Y
+	}
+	if right[1] <= right[0] {
+		t.Errorf("got %d with wrap, want more than %d with clamp", right[1], right[0])
+	}
+}
+
+func Test_EdgeTransparent(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []uint8{0xff, 0, 0, 0xff})
+	}
+	m, err := ResizeWithOptions(8, 8, img, Bilinear, &Options{Edge: EdgeTransparent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := m.(*image.RGBA)
+	if c := rgba.RGBAAt(7, 7); c.A == 0xff || c.R != c.A {
+		t.Errorf("got %v in the corner, want partially transparent red", c)
+	}
+	if c := rgba.RGBAAt(4, 4); c != (color.RGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("got %v in the center, want opaque red", c)
+	}
+
+	// Gray images have no alpha channel and are clamped.
+	gray := image.NewGray(image.Rect(0, 0, 16, 16))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i)
+	}
+	want, _ := Resize(8, 8, gray, Bilinear)
+	got, _ := ResizeWithOptions(8, 8, gray, Bilinear, &Options{Edge: EdgeTransparent})
+	if !bytes.Equal(want.(*image.Gray).Pix, got.(*image.Gray).Pix) {
+		t.Error("transparent edges changed a gray image")
+	}
+}
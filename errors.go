@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+var (
+	// ErrEmptyImage is returned when an image without pixels is to be
+	// resized to a non-empty size.
+	ErrEmptyImage = errors.New("resize: empty source image")
+	// ErrInvalidSize is returned for target sizes that can't be
+	// represented in memory.
+	ErrInvalidSize = errors.New("resize: invalid target size")
+	// ErrInvalidRegion is returned for source regions that are empty or
+	// not finite.
+	ErrInvalidRegion = errors.New("resize: invalid source region")
+	// ErrInvalidBlur is returned for an Options.Blur outside of the
+	// range MinBlur to MaxBlur.
+	ErrInvalidBlur = errors.New("resize: blur out of range")
+	// ErrInvalidSharpen is returned for a Sharpen with a negative amount,
+	// a radius outside of 0 to MaxSharpenRadius or a threshold outside of
+	// 0 to 1.
+	ErrInvalidSharpen = errors.New("resize: invalid sharpen parameters")
+	// ErrUnsupportedStream is returned by ResizeStream for color models
+	// and edge modes it doesn't support.
+	ErrUnsupportedStream = errors.New("resize: unsupported stream")
+)
+
+// A Pass identifies one of the two filter passes of a resize operation.
+type Pass int
+
+// The filter passes, in the order they are run.
+const (
+	// Horizontal filter from the source to the transposed temporary image
+	Horizontal Pass = iota
+	// Vertical filter from the temporary image to the result
+	Vertical
+)
+
+func (p Pass) String() string {
+	switch p {
+	case Horizontal:
+		return "horizontal"
+	case Vertical:
+		return "vertical"
+	}
+	return fmt.Sprintf("Pass(%d)", int(p))
+}
+
+// A PanicError is returned when a worker panicked while filtering.
+type PanicError struct {
+	// Pass is the filter pass the worker was running.
+	Pass Pass
+	// Slice are the bounds of the output slice the worker was filtering.
+	Slice image.Rectangle
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the worker at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("resize: panic in %v pass, slice %v: %v", e.Pass, e.Slice, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Errors is returned when several workers of a pass failed.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// Unwrap returns the errors for use with errors.Is and errors.As.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// checkSize validates the target size of resizing an image with bounds b.
+func checkSize(width, height uint, b image.Rectangle) error {
+	if width > math.MaxInt32 || height > math.MaxInt32 {
+		return ErrInvalidSize
+	}
+	// Images use up to 8 bytes per pixel, the temporary image
+	// has the height of the source.
+	max := uint64(maxInt / 8)
+	if uint64(width)*uint64(height) > max || uint64(width)*uint64(b.Dy()) > max {
+		return ErrInvalidSize
+	}
+	return nil
+}
+
+const maxInt = int(^uint(0) >> 1)
@@ -0,0 +1,67 @@
+package resize
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io"
+	"testing"
+)
+
+func Test_PanicError(t *testing.T) {
+	r := newResizer(nil, &Options{Workers: 2})
+	out := image.NewGray(image.Rect(0, 0, 10, 100))
+	err := r.resizePass(context.Background(), Vertical, out, func(slice image.Image) {
+		if slice.Bounds().Min.Y == 42 {
+			panic(io.EOF)
+		}
+	})
+
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("got %T, want *PanicError", err)
+	}
+	if pe.Pass != Vertical || pe.Slice != image.Rect(0, 42, 10, 57) {
+		t.Errorf("got pass %v, slice %v", pe.Pass, pe.Slice)
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Error("panic value is not unwrapped")
+	}
+	if len(pe.Stack) == 0 {
+		t.Error("missing stack trace")
+	}
+}
+
+func Test_MultiplePanics(t *testing.T) {
+	r := newResizer(nil, &Options{Workers: 3})
+	out := image.NewGray(image.Rect(0, 0, 10, 100))
+	err := r.resizePass(context.Background(), Horizontal, out, func(slice image.Image) {
+		if slice.Bounds().Min.Y == 0 {
+			panic("bad slice")
+		}
+		if slice.Bounds().Min.Y >= 64 {
+			panic(io.ErrUnexpectedEOF)
+		}
+	})
+
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) < 2 {
+		t.Fatalf("got %v, want several errors", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("panic value is not unwrapped")
+	}
+}
+
+func Test_InvalidInput(t *testing.T) {
+	empty := image.NewRGBA(image.Rect(0, 0, 0, 10))
+	if _, err := Resize(10, 10, empty, Bilinear); err != ErrEmptyImage {
+		t.Errorf("got %v, want ErrEmptyImage", err)
+	}
+	if err := ResizeInto(image.NewRGBA(image.Rect(0, 0, 5, 5)), empty, Bilinear); err != ErrEmptyImage {
+		t.Errorf("got %v, want ErrEmptyImage", err)
+	}
+	if _, err := Resize(1<<31, 1, img, Bilinear); err != ErrInvalidSize {
+		t.Errorf("got %v, want ErrInvalidSize", err)
+	}
+}
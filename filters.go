@@ -57,6 +57,28 @@ func mitchellnetravali(in float64) float64 {
 	return 0
 }
 
+// bcCubic returns the cubic kernel of the Mitchell-Netravali family with the
+// parameters b and c, with the polynomial coefficients computed up front.
+func bcCubic(b, c float64) func(float64) float64 {
+	p0 := (6 - 2*b) / 6
+	p2 := (-18 + 12*b + 6*c) / 6
+	p3 := (12 - 9*b - 6*c) / 6
+	q0 := (8*b + 24*c) / 6
+	q1 := (-12*b - 48*c) / 6
+	q2 := (6*b + 30*c) / 6
+	q3 := (-b - 6*c) / 6
+	return func(in float64) float64 {
+		in = math.Abs(in)
+		if in < 1 {
+			return p0 + in*in*(p2+in*p3)
+		}
+		if in < 2 {
+			return q0 + in*(q1+in*(q2+in*q3))
+		}
+		return 0
+	}
+}
+
 func sinc(x float64) float64 {
 	x = math.Abs(x) * math.Pi
 	if x >= 1.220703e-4 {
@@ -79,16 +101,91 @@ func lanczos3(in float64) float64 {
 	return 0
 }
 
+func gaussian(in float64) float64 {
+	in = math.Abs(in)
+	if in < 1.5 {
+		// sigma = 0.5
+		return math.Exp(-2 * in * in)
+	}
+	return 0
+}
+
+func hermite(in float64) float64 {
+	in = math.Abs(in)
+	if in <= 1 {
+		return in*in*(2*in-3) + 1
+	}
+	return 0
+}
+
+func bspline(in float64) float64 {
+	in = math.Abs(in)
+	if in <= 1 {
+		return (in*in*(3*in-6) + 4) / 6
+	}
+	if in <= 2 {
+		in = 2 - in
+		return in * in * in / 6
+	}
+	return 0
+}
+
+// lanczos returns a Lanczos kernel with the given number of lobes.
+func lanczos(lobes float64) func(float64) float64 {
+	return windowedSinc(lobes, sinc)
+}
+
+// windowedSinc returns a sinc kernel of the given support, multiplied by
+// window. The window is evaluated on x/support, in the range [-1,1].
+func windowedSinc(support float64, window func(float64) float64) func(float64) float64 {
+	return func(in float64) float64 {
+		if in > -support && in < support {
+			return sinc(in) * window(in/support)
+		}
+		return 0
+	}
+}
+
+func hann(x float64) float64 {
+	return 0.5 + 0.5*math.Cos(math.Pi*x)
+}
+
+func hamming(x float64) float64 {
+	return 0.54 + 0.46*math.Cos(math.Pi*x)
+}
+
+func blackman(x float64) float64 {
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
+
+// Shape parameter of the Kaiser window.
+const kaiserBeta = 6.5
+
+func kaiser(x float64) float64 {
+	return bessel0(kaiserBeta*math.Sqrt(1-x*x)) / bessel0(kaiserBeta)
+}
+
+// bessel0 is the zeroth order modified Bessel function of the first kind.
+func bessel0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > sum*1e-12; k++ {
+		term *= (x / 2) * (x / 2) / float64(k*k)
+		sum += term
+	}
+	return sum
+}
+
 // range [-256,256]
-func createWeights8(dy, filterLength int, blur, scale float64, kernel func(float64) float64) ([]int16, []int, int) {
+func createWeights8(dy int, filter *Filter, blur, scale, origin float64) ([]int16, []int, int) {
+	filterLength, kernel := filter.kernel()
 	filterLength = filterLength * int(math.Max(math.Ceil(blur*scale), 1))
 	filterFactor := math.Min(1./(blur*scale), 1)
 
 	coeffs := make([]int16, dy*filterLength)
 	start := make([]int, dy)
 	for y := 0; y < dy; y++ {
-		interpX := scale * (float64(y) + 0.5)
-		start[y] = int(interpX) - filterLength/2 + 1
+		interpX := origin + scale*(float64(y)+0.5)
+		start[y] = int(math.Floor(interpX)) - filterLength/2 + 1
 		interpX -= float64(start[y])
 		for i := 0; i < filterLength; i++ {
 			in := (interpX - float64(i)) * filterFactor
@@ -100,15 +197,16 @@ func createWeights8(dy, filterLength int, blur, scale float64, kernel func(float
 }
 
 // range [-65536,65536]
-func createWeights16(dy, filterLength int, blur, scale float64, kernel func(float64) float64) ([]int32, []int, int) {
+func createWeights16(dy int, filter *Filter, blur, scale, origin float64) ([]int32, []int, int) {
+	filterLength, kernel := filter.kernel()
 	filterLength = filterLength * int(math.Max(math.Ceil(blur*scale), 1))
 	filterFactor := math.Min(1./(blur*scale), 1)
 
 	coeffs := make([]int32, dy*filterLength)
 	start := make([]int, dy)
 	for y := 0; y < dy; y++ {
-		interpX := scale * (float64(y) + 0.5)
-		start[y] = int(interpX) - filterLength/2 + 1
+		interpX := origin + scale*(float64(y)+0.5)
+		start[y] = int(math.Floor(interpX)) - filterLength/2 + 1
 		interpX -= float64(start[y])
 		for i := 0; i < filterLength; i++ {
 			in := (interpX - float64(i)) * filterFactor
@@ -119,15 +217,15 @@ func createWeights16(dy, filterLength int, blur, scale float64, kernel func(floa
 	return coeffs, start, filterLength
 }
 
-func createWeightsNearest(dy, filterLength int, blur, scale float64) ([]bool, []int, int) {
+func createWeightsNearest(dy, filterLength int, blur, scale, origin float64) ([]bool, []int, int) {
 	filterLength = filterLength * int(math.Max(math.Ceil(blur*scale), 1))
 	filterFactor := math.Min(1./(blur*scale), 1)
 
 	coeffs := make([]bool, dy*filterLength)
 	start := make([]int, dy)
 	for y := 0; y < dy; y++ {
-		interpX := scale * (float64(y) + 0.5)
-		start[y] = int(interpX) - filterLength/2 + 1
+		interpX := origin + scale*(float64(y)+0.5)
+		start[y] = int(math.Floor(interpX)) - filterLength/2 + 1
 		interpX -= float64(start[y])
 		for i := 0; i < filterLength; i++ {
 			in := (interpX - float64(i)) * filterFactor
@@ -141,3 +239,53 @@ func createWeightsNearest(dy, filterLength int, blur, scale float64) ([]bool, []
 
 	return coeffs, start, filterLength
 }
+
+// areaCoverage returns the fraction of each source pixel covered by the
+// output pixels, which span [origin+scale*y, origin+scale*(y+1)). When
+// upscaling, the coverage is relative to the output pixel instead, so that
+// the weights of a row don't round to zero for large factors.
+func areaCoverage(dy int, scale, origin float64) ([]float64, []int, int) {
+	unit := math.Min(scale, 1)
+	filterLength := 1
+	for y := 0; y < dy; y++ {
+		lo := math.Floor(origin + scale*float64(y))
+		hi := math.Ceil(origin + scale*float64(y+1))
+		if n := int(hi - lo); n > filterLength {
+			filterLength = n
+		}
+	}
+
+	coverage := make([]float64, dy*filterLength)
+	start := make([]int, dy)
+	for y := 0; y < dy; y++ {
+		lo := origin + scale*float64(y)
+		hi := origin + scale*float64(y+1)
+		start[y] = int(math.Floor(lo))
+		for i := 0; i < filterLength; i++ {
+			x := float64(start[y] + i)
+			coverage[y*filterLength+i] = math.Max(math.Min(hi, x+1)-math.Max(lo, x), 0) / unit
+		}
+	}
+
+	return coverage, start, filterLength
+}
+
+// range [0,256]
+func createWeightsArea8(dy int, scale, origin float64) ([]int16, []int, int) {
+	coverage, start, filterLength := areaCoverage(dy, scale, origin)
+	coeffs := make([]int16, len(coverage))
+	for i, c := range coverage {
+		coeffs[i] = int16(math.Round(c * 256))
+	}
+	return coeffs, start, filterLength
+}
+
+// range [0,65536]
+func createWeightsArea16(dy int, scale, origin float64) ([]int32, []int, int) {
+	coverage, start, filterLength := areaCoverage(dy, scale, origin)
+	coeffs := make([]int32, len(coverage))
+	for i, c := range coverage {
+		coeffs[i] = int32(math.Round(c * 65536))
+	}
+	return coeffs, start, filterLength
+}
@@ -0,0 +1,279 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"context"
+	"image"
+	"math"
+	"sync"
+)
+
+var (
+	gammaOnce sync.Once
+	// 8-bit sRGB to 16-bit linear light.
+	linear8 [256]uint16
+	// 16-bit sRGB to 16-bit linear light.
+	linear16 []uint16
+	// 16-bit linear light to 16-bit sRGB.
+	srgb16 []uint16
+)
+
+func initGammaTables() {
+	for i := range linear8 {
+		linear8[i] = uint16(srgbToLinear(float64(i)/0xff)*0xffff + 0.5)
+	}
+	linear16 = make([]uint16, 0x10000)
+	srgb16 = make([]uint16, 0x10000)
+	for i := range linear16 {
+		linear16[i] = uint16(srgbToLinear(float64(i)/0xffff)*0xffff + 0.5)
+		srgb16[i] = uint16(linearToSRGB(float64(i)/0xffff)*0xffff + 0.5)
+	}
+}
+
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(c float64) float64 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// Convert a 16-bit sample to 8 bits, rounding to the nearest value.
+func to8(v uint16) uint8 {
+	return uint8((uint32(v) + 128) / 257)
+}
+
+// linearize converts an alpha-premultiplied 16-bit sRGB color to
+// alpha-premultiplied linear light.
+func linearize(r, g, b, a uint32) (uint16, uint16, uint16) {
+	switch a {
+	case 0:
+		return 0, 0, 0
+	case 0xffff:
+		return linear16[r], linear16[g], linear16[b]
+	}
+	r = uint32(linear16[minUint32(r*0xffff/a, 0xffff)]) * a / 0xffff
+	g = uint32(linear16[minUint32(g*0xffff/a, 0xffff)]) * a / 0xffff
+	b = uint32(linear16[minUint32(b*0xffff/a, 0xffff)]) * a / 0xffff
+	return uint16(r), uint16(g), uint16(b)
+}
+
+// delinearize is the inverse of linearize.
+func delinearize(r, g, b, a uint32) (uint16, uint16, uint16) {
+	switch a {
+	case 0:
+		return 0, 0, 0
+	case 0xffff:
+		return srgb16[r], srgb16[g], srgb16[b]
+	}
+	r = uint32(srgb16[minUint32(r*0xffff/a, 0xffff)]) * a / 0xffff
+	g = uint32(srgb16[minUint32(g*0xffff/a, 0xffff)]) * a / 0xffff
+	b = uint32(srgb16[minUint32(b*0xffff/a, 0xffff)]) * a / 0xffff
+	return uint16(r), uint16(g), uint16(b)
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// toLinear converts img to an image in linear light, with the
+// bounds translated to the origin.
+func toLinear(img image.Image) *image.RGBA64 {
+	gammaOnce.Do(initGammaTables)
+	bounds := img.Bounds()
+	out := image.NewRGBA64(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	switch in := img.(type) {
+	case *image.RGBA:
+		for y := 0; y < bounds.Dy(); y++ {
+			row := in.Pix[y*in.Stride:]
+			dst := out.Pix[y*out.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				p := row[x*4 : x*4+4]
+				var r, g, b uint16
+				if p[3] == 0xff {
+					r, g, b = linear8[p[0]], linear8[p[1]], linear8[p[2]]
+				} else {
+					r, g, b = linearize(uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101, uint32(p[3])*0x101)
+				}
+				setRGBA64(dst[x*8:], r, g, b, uint16(p[3])*0x101)
+			}
+		}
+	case *image.NRGBA:
+		for y := 0; y < bounds.Dy(); y++ {
+			row := in.Pix[y*in.Stride:]
+			dst := out.Pix[y*out.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				p := row[x*4 : x*4+4]
+				a := uint32(p[3]) * 0x101
+				r := uint32(linear8[p[0]]) * a / 0xffff
+				g := uint32(linear8[p[1]]) * a / 0xffff
+				b := uint32(linear8[p[2]]) * a / 0xffff
+				setRGBA64(dst[x*8:], uint16(r), uint16(g), uint16(b), uint16(a))
+			}
+		}
+	case *image.Gray:
+		for y := 0; y < bounds.Dy(); y++ {
+			row := in.Pix[y*in.Stride:]
+			dst := out.Pix[y*out.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				v := linear8[row[x]]
+				setRGBA64(dst[x*8:], v, v, v, 0xffff)
+			}
+		}
+	default:
+		for y := 0; y < bounds.Dy(); y++ {
+			dst := out.Pix[y*out.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				r, g, b, a := img.At(x+bounds.Min.X, y+bounds.Min.Y).RGBA()
+				lr, lg, lb := linearize(r, g, b, a)
+				setRGBA64(dst[x*8:], lr, lg, lb, uint16(a))
+			}
+		}
+	}
+	return out
+}
+
+// fromLinear converts the linear light image m back to sRGB. The result
+// has the same type as like where possible.
+func fromLinear(m *image.RGBA64, like image.Image) image.Image {
+	gammaOnce.Do(initGammaTables)
+	bounds := m.Bounds()
+
+	switch like.(type) {
+	case *image.RGBA, *image.YCbCr:
+		out := image.NewRGBA(bounds)
+		for y := 0; y < bounds.Dy(); y++ {
+			row := m.Pix[y*m.Stride:]
+			dst := out.Pix[y*out.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				r, g, b, a := getRGBA64(row[x*8:])
+				sr, sg, sb := delinearize(r, g, b, a)
+				dst[x*4+0] = to8(sr)
+				dst[x*4+1] = to8(sg)
+				dst[x*4+2] = to8(sb)
+				dst[x*4+3] = to8(uint16(a))
+			}
+		}
+		return out
+	case *image.NRGBA:
+		out := image.NewNRGBA(bounds)
+		for y := 0; y < bounds.Dy(); y++ {
+			row := m.Pix[y*m.Stride:]
+			dst := out.Pix[y*out.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				r, g, b, a := getRGBA64(row[x*8:])
+				if a == 0 {
+					continue
+				}
+				dst[x*4+0] = to8(srgb16[minUint32(r*0xffff/a, 0xffff)])
+				dst[x*4+1] = to8(srgb16[minUint32(g*0xffff/a, 0xffff)])
+				dst[x*4+2] = to8(srgb16[minUint32(b*0xffff/a, 0xffff)])
+				dst[x*4+3] = to8(uint16(a))
+			}
+		}
+		return out
+	case *image.NRGBA64:
+		out := image.NewNRGBA64(bounds)
+		for y := 0; y < bounds.Dy(); y++ {
+			row := m.Pix[y*m.Stride:]
+			dst := out.Pix[y*out.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				r, g, b, a := getRGBA64(row[x*8:])
+				if a == 0 {
+					continue
+				}
+				r = uint32(srgb16[minUint32(r*0xffff/a, 0xffff)])
+				g = uint32(srgb16[minUint32(g*0xffff/a, 0xffff)])
+				b = uint32(srgb16[minUint32(b*0xffff/a, 0xffff)])
+				setRGBA64(dst[x*8:], uint16(r), uint16(g), uint16(b), uint16(a))
+			}
+		}
+		return out
+	case *image.Gray:
+		out := image.NewGray(bounds)
+		for y := 0; y < bounds.Dy(); y++ {
+			row := m.Pix[y*m.Stride:]
+			dst := out.Pix[y*out.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				r, _, _, _ := getRGBA64(row[x*8:])
+				dst[x] = to8(srgb16[r])
+			}
+		}
+		return out
+	case *image.Gray16:
+		out := image.NewGray16(bounds)
+		for y := 0; y < bounds.Dy(); y++ {
+			row := m.Pix[y*m.Stride:]
+			dst := out.Pix[y*out.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				r, _, _, _ := getRGBA64(row[x*8:])
+				v := srgb16[r]
+				dst[x*2+0] = uint8(v >> 8)
+				dst[x*2+1] = uint8(v)
+			}
+		}
+		return out
+	default:
+		for y := 0; y < bounds.Dy(); y++ {
+			row := m.Pix[y*m.Stride:]
+			for x := 0; x < bounds.Dx(); x++ {
+				r, g, b, a := getRGBA64(row[x*8:])
+				sr, sg, sb := delinearize(r, g, b, a)
+				setRGBA64(row[x*8:], sr, sg, sb, uint16(a))
+			}
+		}
+		return m
+	}
+}
+
+func getRGBA64(p []uint8) (r, g, b, a uint32) {
+	r = uint32(p[0])<<8 | uint32(p[1])
+	g = uint32(p[2])<<8 | uint32(p[3])
+	b = uint32(p[4])<<8 | uint32(p[5])
+	a = uint32(p[6])<<8 | uint32(p[7])
+	return
+}
+
+func setRGBA64(p []uint8, r, g, b, a uint16) {
+	p[0] = uint8(r >> 8)
+	p[1] = uint8(r)
+	p[2] = uint8(g >> 8)
+	p[3] = uint8(g)
+	p[4] = uint8(b >> 8)
+	p[5] = uint8(b)
+	p[6] = uint8(a >> 8)
+	p[7] = uint8(a)
+}
+
+func (r *Resizer) resizeLinear(ctx context.Context, width, height uint, scaleX, scaleY, x0, y0 float64, img image.Image) (image.Image, error) {
+	m, err := r.resize(ctx, width, height, scaleX, scaleY, x0, y0, toLinear(img), nil)
+	if err != nil {
+		return nil, err
+	}
+	return fromLinear(m.(*image.RGBA64), img), nil
+}
@@ -0,0 +1,58 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func Test_LinearCheckerboard(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if (x+y)%2 == 0 {
+				img.SetGray(x, y, color.Gray{0xff})
+			}
+		}
+	}
+
+	m, err := ResizeWithOptions(4, 4, img, Bilinear, &Options{Linear: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, ok := m.(*image.Gray)
+	if !ok {
+		t.Fatalf("got %T, want *image.Gray", m)
+	}
+	// 50% linear light is about 188 in sRGB, naive averaging gives 128.
+	for y := 1; y < 3; y++ {
+		for x := 1; x < 3; x++ {
+			if v := out.GrayAt(x, y).Y; v < 186 || v > 190 {
+				t.Errorf("pixel (%d, %d) = %d, want about 188", x, y, v)
+			}
+		}
+	}
+}
+
+func Test_LinearSameColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i+0] = 0x80
+		img.Pix[i+1] = 0x40
+		img.Pix[i+2] = 0x10
+		img.Pix[i+3] = 0xff
+	}
+
+	m, err := ResizeWithOptions(7, 7, img, Lanczos3, &Options{Linear: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := m.(*image.RGBA)
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 7; x++ {
+			if c := out.RGBAAt(x, y); c != (color.RGBA{0x80, 0x40, 0x10, 0xff}) {
+				t.Fatalf("pixel (%d, %d) = %v", x, y, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"math"
+)
+
+// ResizeGIF scales all frames of the animation g to width and height using
+// the interpolation function interp. The frames are composited with their
+// disposal methods before resizing, so frames covering only part of the
+// image stay aligned, and each resized frame is re-quantized to its
+// palette, dithered if opts.Dither is set. Pixels a frame leaves
+// transparent stay transparent, so the previous frames show through as
+// before. Delays, disposal methods and the loop count are kept. If one of
+// width or height is 0, it is calculated from the aspect ratio of g.
+func ResizeGIF(width, height uint, g *gif.GIF, interp InterpolationFunction, opts *Options) (*gif.GIF, error) {
+	return newResizer(interp, opts).ResizeGIF(width, height, g)
+}
+
+// ResizeGIF scales all frames of g to width and height, see the package
+// level ResizeGIF.
+func (r *Resizer) ResizeGIF(width, height uint, g *gif.GIF) (*gif.GIF, error) {
+	if err := r.opts.validate(); err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 0 {
+		return nil, ErrEmptyImage
+	}
+	screen := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if screen.Empty() {
+		for _, m := range g.Image {
+			screen = screen.Union(m.Bounds())
+		}
+		screen.Min = image.Point{}
+	}
+	if err := r.opts.Limits.checkSource(screen); err != nil {
+		return nil, err
+	}
+	if screen.Empty() {
+		return nil, ErrEmptyImage
+	}
+	scaleX, scaleY := calcFactors(width, height, float64(screen.Dx()), float64(screen.Dy()))
+	if width == 0 {
+		width = scaledSize(float64(screen.Dx()), scaleX)
+	}
+	if height == 0 {
+		height = scaledSize(float64(screen.Dy()), scaleY)
+	}
+	if int(width) == screen.Dx() && int(height) == screen.Dy() {
+		return g, nil
+	}
+	if err := checkSize(width, height, screen); err != nil {
+		return nil, err
+	}
+	if err := r.opts.Limits.checkOutput(width, height); err != nil {
+		return nil, err
+	}
+	// The canvas and the copy kept for DisposalPrevious come on top of
+	// resizing the canvas.
+	mem := r.estimateMemory(width, height, scaleX, scaleY, &image.NRGBA{Rect: screen})
+	if err := r.opts.Limits.checkMemory(mem + 8*int64(screen.Dx())*int64(screen.Dy())); err != nil {
+		return nil, err
+	}
+	canvas := image.NewNRGBA(screen)
+
+	out := &gif.GIF{
+		Image:           make([]*image.Paletted, len(g.Image)),
+		Delay:           append([]int(nil), g.Delay...),
+		Disposal:        append([]byte(nil), g.Disposal...),
+		LoopCount:       g.LoopCount,
+		Config:          g.Config,
+		BackgroundIndex: g.BackgroundIndex,
+	}
+	out.Config.Width, out.Config.Height = int(width), int(height)
+
+	ctx := context.Background()
+	var drawer draw.Drawer = draw.Src
+	if r.opts.Dither {
+		drawer = draw.FloydSteinberg
+	}
+	var previous *image.NRGBA
+	for i, frame := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(screen)
+			copy(previous.Pix, canvas.Pix)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		// Only the part of the composite covered by the frame is
+		// resized, with the sampling positions of the whole image.
+		rect := scaleRect(frame.Bounds().Intersect(screen), scaleX, scaleY, int(width), int(height))
+		m, err := r.scale(ctx, uint(rect.Dx()), uint(rect.Dy()), scaleX, scaleY,
+			float64(rect.Min.X)*scaleX, float64(rect.Min.Y)*scaleY, canvas, nil)
+		if err != nil {
+			return nil, err
+		}
+		palette := frame.Palette
+		if len(palette) == 0 {
+			palette = medianCut(m.(*image.NRGBA), 256)
+		}
+		p := image.NewPaletted(rect, palette)
+		drawer.Draw(p, rect, m, image.Point{})
+		if t := transparentIndex(frame.Palette); t >= 0 {
+			// Pixels the frame leaves transparent keep showing the
+			// previous frames, whose colors the palette of the frame
+			// may not hold.
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				for x := rect.Min.X; x < rect.Max.X; x++ {
+					sx := int(math.Floor((float64(x) + 0.5) * scaleX))
+					sy := int(math.Floor((float64(y) + 0.5) * scaleY))
+					if !image.Pt(sx, sy).In(frame.Bounds()) || frame.Pix[frame.PixOffset(sx, sy)] == uint8(t) {
+						p.Pix[p.PixOffset(x, y)] = uint8(t)
+					}
+				}
+			}
+		}
+		out.Image[i] = p
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas, previous = previous, nil
+		}
+	}
+	return out, nil
+}
+
+// transparentIndex returns the index of the first transparent color of
+// palette, -1 if there is none.
+func transparentIndex(palette color.Palette) int {
+	for i, c := range palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// scaleRect returns the smallest rectangle of the resized image covering
+// the source rectangle b, at least one pixel in size.
+func scaleRect(b image.Rectangle, scaleX, scaleY float64, width, height int) image.Rectangle {
+	x0 := clampInt(int(math.Floor(float64(b.Min.X)/scaleX)), 0, width-1)
+	y0 := clampInt(int(math.Floor(float64(b.Min.Y)/scaleY)), 0, height-1)
+	x1 := clampInt(int(math.Ceil(float64(b.Max.X)/scaleX)), x0+1, width)
+	y1 := clampInt(int(math.Ceil(float64(b.Max.Y)/scaleY)), y0+1, height)
+	return image.Rect(x0, y0, x1, y1)
+}
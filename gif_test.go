@@ -0,0 +1,166 @@
+package resize
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+// render returns what is visible after the frame n of g is drawn.
+func render(g *gif.GIF, n int) *image.NRGBA {
+	canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	for i, frame := range g.Image[:n+1] {
+		previous := image.NewNRGBA(canvas.Rect)
+		copy(previous.Pix, canvas.Pix)
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if i == n {
+			break
+		}
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return canvas
+}
+
+func Test_ResizeGIF(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}}
+	full := image.NewPaletted(image.Rect(0, 0, 40, 40), palette)
+	for i := range full.Pix {
+		full.Pix[i] = 1
+	}
+	part := image.NewPaletted(image.Rect(20, 20, 40, 40), palette)
+	for i := range part.Pix {
+		part.Pix[i] = 2
+	}
+	g := &gif.GIF{
+		Image:     []*image.Paletted{full, part},
+		Delay:     []int{10, 20},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalBackground},
+		LoopCount: 3,
+		Config:    image.Config{ColorModel: palette, Width: 40, Height: 40},
+	}
+
+	out, err := ResizeGIF(20, 0, g, Bilinear, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Config.Width != 20 || out.Config.Height != 20 {
+		t.Errorf("got config %dx%d, want 20x20", out.Config.Width, out.Config.Height)
+	}
+	if out.LoopCount != 3 || out.Delay[1] != 20 || out.Disposal[1] != gif.DisposalBackground {
+		t.Error("timing or disposal not preserved")
+	}
+	if b := out.Image[1].Bounds(); b != image.Rect(10, 10, 20, 20) {
+		t.Errorf("got frame bounds %v, want %v", b, image.Rect(10, 10, 20, 20))
+	}
+	if i := out.Image[1].ColorIndexAt(15, 15); i != 2 {
+		t.Errorf("got index %d inside the second frame, want 2", i)
+	}
+	if i := out.Image[0].ColorIndexAt(5, 5); i != 1 {
+		t.Errorf("got index %d inside the first frame, want 1", i)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gif.DecodeAll(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ResizeGIFDisposal(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.NRGBA{255, 0, 0, 255}}
+	red := image.NewPaletted(image.Rect(0, 0, 40, 40), palette)
+	for i := range red.Pix {
+		red.Pix[i] = 1
+	}
+	// Transparent frame, what is visible depends on the disposal of the
+	// first frame.
+	empty := image.NewPaletted(image.Rect(0, 0, 40, 40), palette)
+
+	for _, c := range []struct {
+		disposal byte
+		want     color.NRGBA
+	}{
+		{gif.DisposalNone, color.NRGBA{255, 0, 0, 255}},
+		{gif.DisposalBackground, color.NRGBA{}},
+	} {
+		g := &gif.GIF{
+			Image:    []*image.Paletted{red, empty},
+			Delay:    []int{0, 0},
+			Disposal: []byte{c.disposal, gif.DisposalNone},
+			Config:   image.Config{ColorModel: palette, Width: 40, Height: 40},
+		}
+		out, err := ResizeGIF(10, 10, g, Bilinear, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := render(out, 1).NRGBAAt(5, 5); got != c.want {
+			t.Errorf("disposal %d: got %v, want %v", c.disposal, got, c.want)
+		}
+	}
+}
+
+func Test_ResizeGIFLocalPalette(t *testing.T) {
+	red, blue, green := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}, color.NRGBA{0, 255, 0, 255}
+	first := image.NewPaletted(image.Rect(0, 0, 40, 40), color.Palette{red, blue})
+	for i := range first.Pix {
+		first.Pix[i] = uint8(i % 40 / 20)
+	}
+	// An optimized frame: its local palette only holds the color that
+	// changed, the rest of it is transparent.
+	second := image.NewPaletted(image.Rect(0, 0, 40, 40), color.Palette{green, color.Transparent})
+	for i := range second.Pix {
+		if i%40 >= 10 || i/40 >= 10 {
+			second.Pix[i] = 1
+		}
+	}
+	g := &gif.GIF{
+		Image:    []*image.Paletted{first, second},
+		Delay:    []int{0, 0},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone},
+		Config:   image.Config{Width: 40, Height: 40},
+	}
+	out, err := ResizeGIF(20, 20, g, Bilinear, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := render(out, 1)
+	for _, c := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{2, 2, green},
+		{2, 15, red},
+		{15, 15, blue},
+		{15, 2, blue},
+	} {
+		if got := m.NRGBAAt(c.x, c.y); got != c.want {
+			t.Errorf("got %v at %d,%d, want %v", got, c.x, c.y, c.want)
+		}
+	}
+}
+
+func Test_ResizeGIFLimits(t *testing.T) {
+	// The limit is checked before the canvas of the logical screen is
+	// allocated.
+	palette := color.Palette{color.Transparent, color.NRGBA{255, 0, 0, 255}}
+	g := &gif.GIF{
+		Image:  []*image.Paletted{image.NewPaletted(image.Rect(0, 0, 10, 10), palette)},
+		Delay:  []int{0},
+		Config: image.Config{Width: 60000, Height: 60000},
+	}
+	_, err := ResizeGIF(100, 100, g, Bilinear, &Options{Limits: Limits{MaxMemory: 1 << 20}})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got error %v, want %v", err, ErrLimitExceeded)
+	}
+}
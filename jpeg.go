@@ -0,0 +1,669 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+// ResizeJPEG decodes a JPEG image from r and scales it to width and height
+// using the interpolation function interp and the options opts, which may
+// be nil. Large reductions are partly done while decoding: baseline JPEGs
+// are decoded at 1/2, 1/4 or 1/8 of their size by scaling the inverse DCT,
+// whichever is the smallest that still covers the target size, which
+// saves most of the decoding work for thumbnails. Progressive JPEGs are
+// decoded at full size. Options.Limits are checked against the size in
+// the header before decoding.
+// If one of the parameters width or height is set to 0, its size will be
+// calculated by preserving the aspect ratio.
+func ResizeJPEG(width, height uint, r io.Reader, interp InterpolationFunction, opts *Options) (image.Image, error) {
+	return newResizer(interp, opts).ResizeJPEG(width, height, r)
+}
+
+// ResizeJPEG decodes a JPEG image from rd and scales it to width and
+// height, see the package level ResizeJPEG.
+func (r *Resizer) ResizeJPEG(width, height uint, rd io.Reader) (image.Image, error) {
+	if err := r.opts.validate(); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := image.Rect(0, 0, config.Width, config.Height)
+	if err := r.opts.Limits.checkSource(bounds); err != nil {
+		return nil, err
+	}
+	denom := 1
+	if !bounds.Empty() {
+		scaleX, scaleY := calcFactors(width, height, float64(config.Width), float64(config.Height))
+		if width == 0 {
+			width = scaledSize(float64(config.Width), scaleX)
+		}
+		if height == 0 {
+			height = scaledSize(float64(config.Height), scaleY)
+		}
+		denom = jpegDenom(width, height, config.Width, config.Height)
+
+		// The limits are checked before decoding, with an image of the
+		// decoded type and size that has no pixels.
+		decoded, bpp := jpegImage(config.ColorModel, image.Rect(0, 0, (config.Width+denom-1)/denom, (config.Height+denom-1)/denom))
+		b := decoded.Bounds()
+		if err := checkSize(width, height, b); err != nil {
+			return nil, err
+		}
+		if err := r.opts.Limits.checkOutput(width, height); err != nil {
+			return nil, err
+		}
+		mem := int64(bpp)*int64(b.Dx())*int64(b.Dy()) +
+			r.estimateMemory(width, height, float64(b.Dx())/float64(width), float64(b.Dy())/float64(height), decoded)
+		if err := r.opts.Limits.checkMemory(mem); err != nil {
+			return nil, err
+		}
+	}
+	img, err := decodeJPEG(data, denom)
+	if err != nil {
+		return nil, err
+	}
+	return r.Resize(width, height, img)
+}
+
+// jpegImage returns an image without pixels of the type a JPEG with the
+// color model m is decoded to, and the bytes per pixel it needs at most.
+func jpegImage(m color.Model, r image.Rectangle) (image.Image, int) {
+	switch m {
+	case color.GrayModel:
+		return &image.Gray{Rect: r}, 1
+	case color.YCbCrModel:
+		return &image.YCbCr{Rect: r, SubsampleRatio: image.YCbCrSubsampleRatio444}, 3
+	}
+	return &image.CMYK{Rect: r}, 4
+}
+
+// jpegDenom returns the largest of the denominators 2, 4 and 8 a w x h
+// JPEG can be decoded with and still cover width x height pixels, 1 if
+// there is none.
+func jpegDenom(width, height uint, w, h int) int {
+	for _, d := range []int{8, 4, 2} {
+		if (w+d-1)/d >= int(width) && (h+d-1)/d >= int(height) {
+			return d
+		}
+	}
+	return 1
+}
+
+// decodeJPEG decodes data at 1/denom of its size. JPEGs the scaled
+// decoder doesn't support are decoded at full size by image/jpeg.
+func decodeJPEG(data []byte, denom int) (image.Image, error) {
+	if denom > 1 {
+		d := &jpegDecoder{data: data, n: 8 / denom}
+		m, err := d.decode()
+		if _, ok := err.(jpeg.UnsupportedError); !ok {
+			return m, err
+		}
+	}
+	return jpeg.Decode(bytes.NewReader(data))
+}
+
+// JPEG markers
+const (
+	jpegSOF0 = 0xc0 // baseline
+	jpegSOF1 = 0xc1 // extended sequential, Huffman coding
+	jpegDHT  = 0xc4
+	jpegRST0 = 0xd0
+	jpegRST7 = 0xd7
+	jpegSOI  = 0xd8
+	jpegEOI  = 0xd9
+	jpegSOS  = 0xda
+	jpegDQT  = 0xdb
+	jpegDRI  = 0xdd
+	jpegAPPE = 0xee // Adobe
+)
+
+// unzig maps the zig-zag order of the coefficients in the stream to their
+// natural order.
+var unzig = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// idctTables holds the n-point inverse DCT for n = 1, 2 and 4 as n x n
+// matrices from frequency to sample, scaled so that the DC coefficient of
+// the 8x8 DCT yields the mean of the block.
+var idctTables = func() (t [5][]float64) {
+	for _, n := range []int{1, 2, 4} {
+		t[n] = make([]float64, n*n)
+		for x := 0; x < n; x++ {
+			for u := 0; u < n; u++ {
+				c := 0.5
+				if u == 0 {
+					c = 0.5 / math.Sqrt2
+				}
+				t[n][x*n+u] = c * math.Cos(float64((2*x+1)*u)*math.Pi/float64(2*n))
+			}
+		}
+	}
+	return t
+}()
+
+type jpegHuffman struct {
+	vals []uint8
+	// lut maps the next 8 bits to the length of the code in the high
+	// and the value in the low byte, 0 for longer codes.
+	lut     [256]uint16
+	maxCode [17]int32 // largest code of each length, -1 if there is none
+	valPtr  [17]int32 // index of the values of each length minus its first code
+}
+
+type jpegComponent struct {
+	id     uint8
+	h, v   int // sampling factors
+	tq     int // quantization table
+	td, ta int // DC and AC Huffman tables
+	pred   int32
+	pix    []uint8
+	stride int
+}
+
+// jpegDecoder decodes sequential Huffman-coded JPEGs with one or three
+// components at n/8 of their size, keeping only the n x n lowest
+// frequencies of each block.
+type jpegDecoder struct {
+	data []byte
+	pos  int
+	n    int
+
+	// Entropy-coded data is read into the high bits of bits.
+	bits   uint32
+	nbits  int
+	marker bool // a marker ended the entropy-coded data
+
+	width, height int
+	hmax, vmax    int
+	mxx, myy      int // MCUs per row and column
+	comps         []jpegComponent
+	quant         [4][64]int32
+	huff          [2][4]jpegHuffman
+	restart       int
+	adobeRGB      bool
+	img           image.Image
+	scanned       bool
+}
+
+func (d *jpegDecoder) decode() (image.Image, error) {
+	if len(d.data) < 2 || d.data[0] != 0xff || d.data[1] != jpegSOI {
+		return nil, jpeg.FormatError("missing SOI marker")
+	}
+	d.pos = 2
+	for {
+		marker, ok := d.nextMarker()
+		if !ok || marker == jpegEOI {
+			if !d.scanned {
+				return nil, jpeg.FormatError("missing SOS marker")
+			}
+			return d.image()
+		}
+		if marker >= jpegRST0 && marker <= jpegRST7 {
+			continue
+		}
+		seg, err := d.segment()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case marker == jpegSOF0 || marker == jpegSOF1:
+			err = d.parseSOF(seg)
+		case marker >= 0xc2 && marker <= 0xcf && marker != jpegDHT && marker != 0xc8 && marker != 0xcc:
+			err = jpeg.UnsupportedError("progressive, lossless or arithmetic coding")
+		case marker == jpegDHT:
+			err = d.parseDHT(seg)
+		case marker == jpegDQT:
+			err = d.parseDQT(seg)
+		case marker == jpegDRI:
+			if len(seg) != 2 {
+				return nil, jpeg.FormatError("DRI has wrong length")
+			}
+			d.restart = int(seg[0])<<8 | int(seg[1])
+		case marker == jpegAPPE:
+			// An Adobe segment with transform 0 marks RGB samples.
+			if len(seg) >= 12 && string(seg[:5]) == "Adobe" && seg[11] == 0 {
+				d.adobeRGB = true
+			}
+		case marker == jpegSOS:
+			err = d.parseSOS(seg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// nextMarker skips to the next marker and returns it, false at the end of
+// the data.
+func (d *jpegDecoder) nextMarker() (uint8, bool) {
+	for d.pos+1 < len(d.data) {
+		if d.data[d.pos] != 0xff || d.data[d.pos+1] == 0 || d.data[d.pos+1] == 0xff {
+			d.pos++
+			continue
+		}
+		marker := d.data[d.pos+1]
+		d.pos += 2
+		return marker, true
+	}
+	return 0, false
+}
+
+// segment returns the payload of the marker segment at the current
+// position and skips it.
+func (d *jpegDecoder) segment() ([]byte, error) {
+	if d.pos+2 > len(d.data) {
+		return nil, jpeg.FormatError("short segment length")
+	}
+	n := int(d.data[d.pos])<<8 | int(d.data[d.pos+1])
+	if n < 2 || d.pos+n > len(d.data) {
+		return nil, jpeg.FormatError("short segment")
+	}
+	seg := d.data[d.pos+2 : d.pos+n]
+	d.pos += n
+	return seg, nil
+}
+
+func (d *jpegDecoder) parseSOF(seg []byte) error {
+	if d.comps != nil {
+		return jpeg.FormatError("multiple SOF markers")
+	}
+	if len(seg) < 6 {
+		return jpeg.FormatError("short SOF")
+	}
+	if seg[0] != 8 {
+		return jpeg.UnsupportedError("precision")
+	}
+	d.height = int(seg[1])<<8 | int(seg[2])
+	d.width = int(seg[3])<<8 | int(seg[4])
+	nc := int(seg[5])
+	if d.height == 0 || d.width == 0 {
+		return jpeg.UnsupportedError("DNL marker")
+	}
+	if nc != 1 && nc != 3 {
+		return jpeg.UnsupportedError("number of components")
+	}
+	if len(seg) != 6+3*nc {
+		return jpeg.FormatError("SOF has wrong length")
+	}
+	d.comps = make([]jpegComponent, nc)
+	for i := range d.comps {
+		c := &d.comps[i]
+		c.id = seg[6+3*i]
+		c.h = int(seg[7+3*i] >> 4)
+		c.v = int(seg[7+3*i] & 0x0f)
+		c.tq = int(seg[8+3*i])
+		if c.h < 1 || c.h > 4 || c.v < 1 || c.v > 4 || c.tq > 3 {
+			return jpeg.FormatError("bad component")
+		}
+	}
+	if nc == 1 {
+		// A single component is never interleaved, its MCU is one block.
+		d.comps[0].h, d.comps[0].v = 1, 1
+	}
+	d.hmax, d.vmax = d.comps[0].h, d.comps[0].v
+	d.mxx = (d.width + 8*d.hmax - 1) / (8 * d.hmax)
+	d.myy = (d.height + 8*d.vmax - 1) / (8 * d.vmax)
+	w, h := d.n*d.hmax*d.mxx, d.n*d.vmax*d.myy
+
+	if nc == 1 {
+		m := image.NewGray(image.Rect(0, 0, w, h))
+		d.comps[0].pix, d.comps[0].stride = m.Pix, m.Stride
+		d.img = m
+		return nil
+	}
+	cb, cr := d.comps[1], d.comps[2]
+	if cb.h != cr.h || cb.v != cr.v || d.hmax%cb.h != 0 || d.vmax%cb.v != 0 {
+		return jpeg.UnsupportedError("sampling factors")
+	}
+	var ratio image.YCbCrSubsampleRatio
+	switch [2]int{d.hmax / cb.h, d.vmax / cb.v} {
+	case [2]int{1, 1}:
+		ratio = image.YCbCrSubsampleRatio444
+	case [2]int{2, 1}:
+		ratio = image.YCbCrSubsampleRatio422
+	case [2]int{2, 2}:
+		ratio = image.YCbCrSubsampleRatio420
+	case [2]int{1, 2}:
+		ratio = image.YCbCrSubsampleRatio440
+	case [2]int{4, 1}:
+		ratio = image.YCbCrSubsampleRatio411
+	case [2]int{4, 2}:
+		ratio = image.YCbCrSubsampleRatio410
+	default:
+		return jpeg.UnsupportedError("sampling factors")
+	}
+	m := image.NewYCbCr(image.Rect(0, 0, w, h), ratio)
+	d.comps[0].pix, d.comps[0].stride = m.Y, m.YStride
+	d.comps[1].pix, d.comps[1].stride = m.Cb, m.CStride
+	d.comps[2].pix, d.comps[2].stride = m.Cr, m.CStride
+	d.img = m
+	return nil
+}
+
+func (d *jpegDecoder) parseDQT(seg []byte) error {
+	for len(seg) > 0 {
+		pq, tq := seg[0]>>4, seg[0]&0x0f
+		if pq > 1 || tq > 3 {
+			return jpeg.FormatError("bad Pq/Tq value")
+		}
+		size := 64 * int(pq+1)
+		if len(seg) < 1+size {
+			return jpeg.FormatError("short DQT")
+		}
+		for i := range d.quant[tq] {
+			if pq == 0 {
+				d.quant[tq][i] = int32(seg[1+i])
+			} else {
+				d.quant[tq][i] = int32(seg[1+2*i])<<8 | int32(seg[2+2*i])
+			}
+		}
+		seg = seg[1+size:]
+	}
+	return nil
+}
+
+func (d *jpegDecoder) parseDHT(seg []byte) error {
+	for len(seg) > 0 {
+		if len(seg) < 17 {
+			return jpeg.FormatError("short DHT")
+		}
+		tc, th := seg[0]>>4, seg[0]&0x0f
+		if tc > 1 || th > 3 {
+			return jpeg.FormatError("bad Tc/Th value")
+		}
+		counts := seg[1:17]
+		total := 0
+		for _, c := range counts {
+			total += int(c)
+		}
+		if total == 0 || total > 256 || len(seg) < 17+total {
+			return jpeg.FormatError("bad DHT")
+		}
+		h := &d.huff[tc][th]
+		*h = jpegHuffman{vals: append([]uint8(nil), seg[17:17+total]...)}
+		code, k := int32(0), int32(0)
+		for l := 1; l <= 16; l++ {
+			h.valPtr[l] = k - code
+			for i := 0; i < int(counts[l-1]); i++ {
+				if code >= 1<<uint(l) {
+					return jpeg.FormatError("bad Huffman table")
+				}
+				if l <= 8 {
+					shift := uint(8 - l)
+					for j := int32(0); j < 1<<shift; j++ {
+						h.lut[code<<shift|j] = uint16(l)<<8 | uint16(h.vals[k])
+					}
+				}
+				code++
+				k++
+			}
+			h.maxCode[l] = -1
+			if counts[l-1] > 0 {
+				h.maxCode[l] = code - 1
+			}
+			code <<= 1
+		}
+		seg = seg[17+total:]
+	}
+	return nil
+}
+
+func (d *jpegDecoder) parseSOS(seg []byte) error {
+	if d.comps == nil {
+		return jpeg.FormatError("missing SOF marker")
+	}
+	if d.adobeRGB || len(d.comps) == 3 && d.comps[0].id == 'R' && d.comps[1].id == 'G' && d.comps[2].id == 'B' {
+		return jpeg.UnsupportedError("RGB color")
+	}
+	if len(seg) < 1 {
+		return jpeg.FormatError("short SOS")
+	}
+	ns := int(seg[0])
+	if ns < 1 || ns > len(d.comps) || len(seg) != 4+2*ns {
+		return jpeg.FormatError("SOS has wrong length")
+	}
+	scan := make([]*jpegComponent, ns)
+	for i := range scan {
+		id := seg[1+2*i]
+		for j := range d.comps {
+			if d.comps[j].id == id {
+				scan[i] = &d.comps[j]
+			}
+		}
+		if scan[i] == nil {
+			return jpeg.FormatError("unknown component selector")
+		}
+		scan[i].td, scan[i].ta = int(seg[2+2*i]>>4), int(seg[2+2*i]&0x0f)
+		if scan[i].td > 3 || scan[i].ta > 3 {
+			return jpeg.FormatError("bad Td/Ta value")
+		}
+		if d.huff[0][scan[i].td].vals == nil || d.huff[1][scan[i].ta].vals == nil {
+			return jpeg.FormatError("missing Huffman table")
+		}
+	}
+	if ss, se, a := seg[1+2*ns], seg[2+2*ns], seg[3+2*ns]; ss != 0 || se != 63 || a != 0 {
+		return jpeg.UnsupportedError("spectral selection")
+	}
+	d.scanned = true
+
+	d.bits, d.nbits, d.marker = 0, 0, false
+	for _, c := range scan {
+		c.pred = 0
+	}
+	var bx, by int
+	if ns == 1 {
+		// Non-interleaved, each block of the component is an MCU.
+		c := scan[0]
+		bx = ((d.width*c.h+d.hmax-1)/d.hmax + 7) / 8
+		by = ((d.height*c.v+d.vmax-1)/d.vmax + 7) / 8
+	} else {
+		bx, by = d.mxx, d.myy
+	}
+	mcu := 0
+	for my := 0; my < by; my++ {
+		for mx := 0; mx < bx; mx++ {
+			if d.restart > 0 && mcu > 0 && mcu%d.restart == 0 {
+				if err := d.resync(scan); err != nil {
+					return err
+				}
+			}
+			mcu++
+			if ns == 1 {
+				if err := d.decodeBlock(scan[0], mx, my); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, c := range scan {
+				for j := 0; j < c.v; j++ {
+					for i := 0; i < c.h; i++ {
+						if err := d.decodeBlock(c, mx*c.h+i, my*c.v+j); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resync skips to the restart marker expected after a restart interval
+// and resets the decoder state.
+func (d *jpegDecoder) resync(scan []*jpegComponent) error {
+	marker, ok := d.nextMarker()
+	if !ok || marker < jpegRST0 || marker > jpegRST7 {
+		return jpeg.FormatError("missing RST marker")
+	}
+	d.bits, d.nbits, d.marker = 0, 0, false
+	for _, c := range scan {
+		c.pred = 0
+	}
+	return nil
+}
+
+// fill reads entropy-coded bytes until there are more than 24 bits.
+// After a marker or the end of the data, zeros are read.
+func (d *jpegDecoder) fill() {
+	for d.nbits <= 24 {
+		var b uint8
+		if !d.marker && d.pos < len(d.data) {
+			b = d.data[d.pos]
+			if b != 0xff {
+				d.pos++
+			} else if d.pos+1 < len(d.data) && d.data[d.pos+1] == 0 {
+				// Stuffed zero byte.
+				d.pos += 2
+			} else {
+				d.marker = true
+				b = 0
+			}
+		}
+		d.bits |= uint32(b) << uint(24-d.nbits)
+		d.nbits += 8
+	}
+}
+
+// receive returns the next n bits.
+func (d *jpegDecoder) receive(n int) int32 {
+	if d.nbits < n {
+		d.fill()
+	}
+	v := int32(d.bits >> uint(32-n))
+	d.bits <<= uint(n)
+	d.nbits -= n
+	return v
+}
+
+// receiveExtend returns the next n bits as a signed value.
+func (d *jpegDecoder) receiveExtend(n int) int32 {
+	v := d.receive(n)
+	if v < 1<<uint(n-1) {
+		v += -1<<uint(n) + 1
+	}
+	return v
+}
+
+func (d *jpegDecoder) decodeHuffman(h *jpegHuffman) (uint8, error) {
+	if d.nbits < 16 {
+		d.fill()
+	}
+	if e := h.lut[d.bits>>24]; e != 0 {
+		l := uint(e >> 8)
+		d.bits <<= l
+		d.nbits -= int(l)
+		return uint8(e), nil
+	}
+	code := int32(0)
+	for l := 1; l <= 16; l++ {
+		code = code<<1 | int32(d.bits>>31)
+		d.bits <<= 1
+		d.nbits--
+		if code <= h.maxCode[l] {
+			return h.vals[code+h.valPtr[l]], nil
+		}
+	}
+	return 0, jpeg.FormatError("bad Huffman code")
+}
+
+// decodeBlock decodes the block at bx, by of the component c and writes
+// its scaled inverse DCT to the component's plane.
+func (d *jpegDecoder) decodeBlock(c *jpegComponent, bx, by int) error {
+	q := &d.quant[c.tq]
+	var blk [64]int32
+	t, err := d.decodeHuffman(&d.huff[0][c.td])
+	if err != nil {
+		return err
+	}
+	if t > 16 {
+		return jpeg.FormatError("bad DC coefficient")
+	}
+	if t > 0 {
+		c.pred += d.receiveExtend(int(t))
+	}
+	blk[0] = c.pred * q[0]
+	for k := 1; k < 64; k++ {
+		rs, err := d.decodeHuffman(&d.huff[1][c.ta])
+		if err != nil {
+			return err
+		}
+		r, s := int(rs>>4), int(rs&0x0f)
+		if s == 0 {
+			if r != 15 {
+				break
+			}
+			k += 15
+			continue
+		}
+		if k += r; k > 63 {
+			return jpeg.FormatError("bad AC coefficient")
+		}
+		blk[unzig[k]] = d.receiveExtend(s) * q[k]
+	}
+
+	// Separable n-point inverse DCT of the n x n lowest frequencies.
+	n := d.n
+	t8 := idctTables[n]
+	var tmp [16]float64
+	for v := 0; v < n; v++ {
+		for x := 0; x < n; x++ {
+			var sum float64
+			for u := 0; u < n; u++ {
+				sum += t8[x*n+u] * float64(blk[v*8+u])
+			}
+			tmp[v*n+x] = sum
+		}
+	}
+	pix := c.pix[by*n*c.stride+bx*n:]
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			sum := 128.5
+			for v := 0; v < n; v++ {
+				sum += t8[y*n+v] * tmp[v*n+x]
+			}
+			pix[y*c.stride+x] = clampUint8(int32(math.Floor(sum)))
+		}
+	}
+	return nil
+}
+
+// image returns the decoded image, cropped to the scaled size.
+func (d *jpegDecoder) image() (image.Image, error) {
+	denom := 8 / d.n
+	r := image.Rect(0, 0, (d.width+denom-1)/denom, (d.height+denom-1)/denom)
+	return d.img.(imageWithSubImage).SubImage(r), nil
+}
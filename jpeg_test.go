@@ -0,0 +1,290 @@
+package resize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"testing"
+)
+
+// encodeJPEG returns a smooth w x h gradient encoded as JPEG, gray or in
+// color.
+func encodeJPEG(t *testing.T, w, h int, gray bool) []byte {
+	var m draw.Image = image.NewRGBA(image.Rect(0, 0, w, h))
+	if gray {
+		m = image.NewGray(image.Rect(0, 0, w, h))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m.Set(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), 0x80, 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, m, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_JPEGDenom(t *testing.T) {
+	for _, c := range []struct {
+		width, height uint
+		w, h          int
+		want          int
+	}{
+		{100, 75, 4000, 3000, 8},
+		{500, 0, 4000, 3000, 8},
+		{501, 0, 4000, 3000, 4},
+		{0, 1000, 4000, 3000, 2},
+		{2500, 1875, 4000, 3000, 1},
+		{13, 13, 100, 100, 8},
+		{14, 13, 100, 100, 4},
+	} {
+		if got := jpegDenom(c.width, c.height, c.w, c.h); got != c.want {
+			t.Errorf("%dx%d from %dx%d: got 1/%d, want 1/%d", c.width, c.height, c.w, c.h, got, c.want)
+		}
+	}
+}
+
+func Test_DecodeJPEGScaled(t *testing.T) {
+	for _, gray := range []bool{false, true} {
+		data := encodeJPEG(t, 203, 131, gray)
+		full, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, denom := range []int{2, 4, 8} {
+			m, err := decodeJPEG(data, denom)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := image.Rect(0, 0, (203+denom-1)/denom, (131+denom-1)/denom); m.Bounds() != want {
+				t.Fatalf("1/%d: got bounds %v, want %v", denom, m.Bounds(), want)
+			}
+			if got, want := fmt.Sprintf("%T", m), fmt.Sprintf("%T", full); got != want {
+				t.Fatalf("1/%d: got %s, want %s", denom, got, want)
+			}
+			// Each pixel is about the mean of its block at full size.
+			b := m.Bounds()
+			for y := 0; y < b.Max.Y-1; y++ {
+				for x := 0; x < b.Max.X-1; x++ {
+					var sum int
+					for i := 0; i < denom*denom; i++ {
+						sum += int(color.GrayModel.Convert(full.At(x*denom+i%denom, y*denom+i/denom)).(color.Gray).Y)
+					}
+					want := sum / (denom * denom)
+					if got := int(color.GrayModel.Convert(m.At(x, y)).(color.Gray).Y); got < want-6 || got > want+6 {
+						t.Fatalf("1/%d: got %d at %d,%d, want about %d", denom, got, x, y, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func Test_ResizeJPEG(t *testing.T) {
+	data := encodeJPEG(t, 640, 480, false)
+	full, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Resize(40, 0, full, Bilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ResizeJPEG(40, 0, bytes.NewReader(data), Bilinear, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != want.Bounds() {
+		t.Fatalf("got bounds %v, want %v", m.Bounds(), want.Bounds())
+	}
+	got := m.(*image.YCbCr)
+	for i, v := range got.Y {
+		if d := int(v) - int(want.(*image.YCbCr).Y[i]); d < -6 || d > 6 {
+			t.Fatalf("got luma %d at %d, want about %d", v, i, want.(*image.YCbCr).Y[i])
+		}
+	}
+
+	if _, err := ResizeJPEG(40, 0, bytes.NewReader(data[:100]), Bilinear, nil); err == nil {
+		t.Error("truncated JPEG decoded without error")
+	}
+}
+
+// encodeBlockJPEG returns a w x h baseline JPEG with flat 8x8 blocks,
+// coding only the DC coefficients. The luma has the sampling factors h, v
+// and the chroma 1, 1; gray images have one component. A restart marker
+// follows every restart MCUs.
+func encodeBlockJPEG(w, ht, h, v, restart int, gray bool) []byte {
+	var buf bytes.Buffer
+	segment := func(marker byte, p ...byte) {
+		buf.Write([]byte{0xff, marker, byte((len(p) + 2) >> 8), byte(len(p) + 2)})
+		buf.Write(p)
+	}
+	nc := 3
+	if gray {
+		nc, h, v = 1, 1, 1
+	}
+	buf.Write([]byte{0xff, jpegSOI})
+	dqt := []byte{0}
+	for i := 0; i < 64; i++ {
+		dqt = append(dqt, 8)
+	}
+	segment(jpegDQT, dqt...)
+	sof := []byte{8, byte(ht >> 8), byte(ht), byte(w >> 8), byte(w), byte(nc), 1, byte(h<<4 | v), 0}
+	if !gray {
+		sof = append(sof, 2, 0x11, 0, 3, 0x11, 0)
+	}
+	segment(jpegSOF0, sof...)
+	// The DC table codes the sizes 0 to 11 in 4 bits, the AC table only
+	// holds the end of block as a 1 bit code.
+	dht := []byte{0x00, 0, 0, 0, 12, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	dht = append(dht, 0x10, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x00)
+	segment(jpegDHT, dht...)
+	if restart > 0 {
+		segment(jpegDRI, byte(restart>>8), byte(restart))
+	}
+	sos := []byte{byte(nc)}
+	for i := 0; i < nc; i++ {
+		sos = append(sos, byte(i+1), 0x00)
+	}
+	segment(jpegSOS, append(sos, 0, 63, 0)...)
+
+	var acc uint32
+	var nacc uint
+	put := func(bits uint32, n uint) {
+		acc, nacc = acc<<n|bits&(1<<n-1), nacc+n
+		for nacc >= 8 {
+			b := byte(acc >> (nacc - 8))
+			buf.WriteByte(b)
+			if b == 0xff {
+				buf.WriteByte(0)
+			}
+			nacc -= 8
+		}
+	}
+	var pred [3]int
+	block := func(c, bx, by int) {
+		// Quantized by 8, the DC coefficient is the sample minus 128.
+		diff := (bx*37+by*59+c*101)%250 + 3 - 128 - pred[c]
+		pred[c] += diff
+		size, bits := 0, diff
+		for a := diff; a != 0; a /= 2 {
+			size++
+		}
+		if diff < 0 {
+			bits = diff + 1<<uint(size) - 1
+		}
+		put(uint32(size), 4)
+		put(uint32(bits), uint(size))
+		put(0, 1) // end of block
+	}
+	mxx, myy := (w+8*h-1)/(8*h), (ht+8*v-1)/(8*v)
+	for mcu := 0; mcu < mxx*myy; mcu++ {
+		if restart > 0 && mcu > 0 && mcu%restart == 0 {
+			put(0x7f, (8-nacc)%8)
+			buf.Write([]byte{0xff, byte(jpegRST0 + (mcu/restart-1)%8)})
+			pred = [3]int{}
+		}
+		mx, my := mcu%mxx, mcu/mxx
+		for j := 0; j < v; j++ {
+			for i := 0; i < h; i++ {
+				block(0, mx*h+i, my*v+j)
+			}
+		}
+		if !gray {
+			block(1, mx, my)
+			block(2, mx, my)
+		}
+	}
+	put(0x7f, (8-nacc)%8)
+	buf.Write([]byte{0xff, jpegEOI})
+	return buf.Bytes()
+}
+
+func Test_DecodeJPEGSampling(t *testing.T) {
+	near := func(a, b uint8) bool { return int(a)-int(b) <= 2 && int(b)-int(a) <= 2 }
+	for _, c := range []struct {
+		name    string
+		h, v    int
+		restart int
+		gray    bool
+	}{
+		{"gray", 1, 1, 0, true},
+		{"gray restart", 1, 1, 7, true},
+		{"4:4:4", 1, 1, 0, false},
+		{"4:2:2", 2, 1, 0, false},
+		{"4:2:0", 2, 2, 0, false},
+		{"4:1:1", 4, 1, 0, false},
+		{"4:4:4 restart", 1, 1, 5, false},
+		{"4:2:0 restart", 2, 2, 3, false},
+		{"4:1:1 restart", 4, 1, 1, false},
+	} {
+		data := encodeBlockJPEG(203, 131, c.h, c.v, c.restart, c.gray)
+		full, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for _, denom := range []int{2, 4, 8} {
+			m, err := decodeJPEG(data, denom)
+			if err != nil {
+				t.Fatalf("%s 1/%d: %v", c.name, denom, err)
+			}
+			if want := image.Rect(0, 0, (203+denom-1)/denom, (131+denom-1)/denom); m.Bounds() != want {
+				t.Fatalf("%s 1/%d: got bounds %v, want %v", c.name, denom, m.Bounds(), want)
+			}
+			if f, ok := full.(*image.YCbCr); ok {
+				if got := m.(*image.YCbCr).SubsampleRatio; got != f.SubsampleRatio {
+					t.Fatalf("%s 1/%d: got ratio %v, want %v", c.name, denom, got, f.SubsampleRatio)
+				}
+			}
+			// The blocks are flat, so each pixel is that of the full size
+			// image at the same position.
+			b := m.Bounds()
+			for y := 0; y < b.Max.Y; y++ {
+				for x := 0; x < b.Max.X; x++ {
+					got := color.RGBAModel.Convert(m.At(x, y)).(color.RGBA)
+					want := color.RGBAModel.Convert(full.At(x*denom, y*denom)).(color.RGBA)
+					if !near(got.R, want.R) || !near(got.G, want.G) || !near(got.B, want.B) {
+						t.Fatalf("%s 1/%d: got %v at %d,%d, want %v", c.name, denom, got, x, y, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func Test_DecodeJPEGMalformed(t *testing.T) {
+	data := encodeJPEG(t, 64, 64, false)
+	// A DHT with three 1 bit codes, one more than there are.
+	dht := []byte{0xff, jpegDHT, 0, 22, 0x00, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2}
+	bad := append(append(append([]byte(nil), data[:2]...), dht...), data[2:]...)
+	if _, err := decodeJPEG(bad, 8); err == nil {
+		t.Error("JPEG with an oversubscribed Huffman table decoded without error")
+	}
+	if _, err := ResizeJPEG(8, 0, bytes.NewReader(bad), Bilinear, nil); err == nil {
+		t.Error("ResizeJPEG of a JPEG with an oversubscribed Huffman table returned no error")
+	}
+}
+
+func Test_ResizeJPEGLimits(t *testing.T) {
+	// An oversubscribed Huffman table makes the scaled decoder fail, a
+	// LimitError shows that the limits are checked before decoding.
+	data := encodeJPEG(t, 300, 300, false)
+	i := bytes.Index(data, []byte{0xff, jpegSOS})
+	dht := []byte{0xff, jpegDHT, 0, 22, 0x00, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2}
+	bad := append(append(append([]byte(nil), data[:i]...), dht...), data[i:]...)
+	for _, limits := range []Limits{{MaxOutputPixels: 100}, {MaxMemory: 1000}} {
+		_, err := ResizeJPEG(30, 30, bytes.NewReader(bad), Bilinear, &Options{Limits: limits})
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%+v: got error %v, want %v", limits, err, ErrLimitExceeded)
+		}
+	}
+	if _, err := ResizeJPEG(30, 30, bytes.NewReader(bad), Bilinear, nil); err == nil || errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got error %v without limits, want a decoding error", err)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// ErrLimitExceeded is matched by every *LimitError, use errors.Is to
+// check for it.
+var ErrLimitExceeded = errors.New("resize: limit exceeded")
+
+// Limits bound the resources of a resize operation. They are checked
+// before any image buffer is allocated. A zero value means no limit.
+type Limits struct {
+	// MaxSourcePixels is the maximum number of pixels of the source image.
+	MaxSourcePixels int64
+	// MaxOutputPixels is the maximum number of pixels of the result.
+	MaxOutputPixels int64
+	// MaxMemory is the maximum number of bytes of the temporary
+	// images, the filter weights and the result.
+	MaxMemory int64
+}
+
+// A LimitError is returned when a resize operation exceeds its Limits.
+type LimitError struct {
+	// Limit is the name of the exceeded field of Limits.
+	Limit string
+	// Value is the required amount.
+	Value int64
+	// Max is the configured limit.
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("resize: %s exceeded: %d > %d", e.Limit, e.Value, e.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+func (l *Limits) checkSource(b image.Rectangle) error {
+	if pixels := int64(b.Dx()) * int64(b.Dy()); l.MaxSourcePixels > 0 && pixels > l.MaxSourcePixels {
+		return &LimitError{"MaxSourcePixels", pixels, l.MaxSourcePixels}
+	}
+	return nil
+}
+
+// checkLimits validates resizing img to width and height by r, sampling
+// it with the scales scaleX and scaleY.
+func (r *Resizer) checkLimits(width, height uint, scaleX, scaleY float64, img image.Image) error {
+	if err := r.opts.Limits.checkOutput(width, height); err != nil {
+		return err
+	}
+	return r.opts.Limits.checkMemory(r.estimateMemory(width, height, scaleX, scaleY, img))
+}
+
+// checkOutput validates the size of the result.
+func (l *Limits) checkOutput(width, height uint) error {
+	if pixels := int64(width) * int64(height); l.MaxOutputPixels > 0 && pixels > l.MaxOutputPixels {
+		return &LimitError{"MaxOutputPixels", pixels, l.MaxOutputPixels}
+	}
+	return nil
+}
+
+// checkMemory validates mem bytes of buffers.
+func (l *Limits) checkMemory(mem int64) error {
+	if l.MaxMemory > 0 && mem > l.MaxMemory {
+		return &LimitError{"MaxMemory", mem, l.MaxMemory}
+	}
+	return nil
+}
+
+// estimateMemory returns the number of bytes allocated by r for resizing
+// img to width and height, sampling it with the scales scaleX and scaleY.
+func (r *Resizer) estimateMemory(width, height uint, scaleX, scaleY float64, img image.Image) int64 {
+	b := img.Bounds()
+	tileMemory := r.opts.TileMemory
+	if r.opts.Edge == EdgeWrap {
+		// The bands at the top and bottom read all rows, see sourceRows.
+		tileMemory = 0
+	}
+	weights := r.weightsMemory(r.interp, width, scaleX) + r.weightsMemory(r.interp, height, scaleY)
+	kx, ky := r.prefilterFactor(scaleX), r.prefilterFactor(scaleY)
+	if kx == 1 && ky == 1 {
+		return weights + estimateMemory(width, height, b, img, r.opts.Linear, tileMemory)
+	}
+	// The Area prefilter of Options.Quality reduces img first, and its
+	// result is resized instead.
+	pw, ph := prefilterSize(b, kx, ky)
+	weights += r.weightsMemory(Area, pw, float64(kx)) + r.weightsMemory(Area, ph, float64(ky))
+	return weights + estimateMemory(pw, ph, b, img, r.opts.Linear, tileMemory) +
+		estimateMemory(width, height, image.Rect(0, 0, int(pw), int(ph)), img, r.opts.Linear, tileMemory)
+}
+
+// weightsMemory returns the number of bytes of the weights of filter for
+// n output pixels sampled with scale, see weightsFor.
+func (r *Resizer) weightsMemory(filter InterpolationFunction, n uint, scale float64) int64 {
+	var filterLength, size int64
+	if filter == Area {
+		// The float64 coverage is converted to the coefficients.
+		filterLength, size = int64(math.Ceil(scale))+1, 8+4
+	} else {
+		taps, _ := filter.kernel()
+		filterLength, size = int64(taps)*int64(math.Max(math.Ceil(r.opts.blur()*scale), 1)), 4
+	}
+	// One offset for each output pixel.
+	return int64(n) * (filterLength*size + 8)
+}
+
+// estimateMemory returns the number of bytes allocated for resizing an
+// image of the type of img with the bounds b to width and height, with the
+// temporary images bounded by tileMemory unless it is 0.
+func estimateMemory(width, height uint, b image.Rectangle, img image.Image, linear bool, tileMemory int64) int64 {
+	src := int64(b.Dx()) * int64(b.Dy())
+	temp := int64(b.Dy()) * int64(width)
+	result := int64(width) * int64(height)
+	tiled := func(n int64) int64 {
+		if tileMemory > 0 && n > tileMemory {
+			return tileMemory
+		}
+		return n
+	}
+
+	if linear {
+		// Linear light copy of the source, 16-bit temporary image and
+		// result and the converted result.
+		return 8*src + tiled(8*temp) + 8*result + 8*result
+	}
+
+	switch img.(type) {
+	case *image.RGBA:
+		return tiled(4*temp) + 4*result
+	case *image.NRGBA:
+		return tiled(8*temp) + 4*result
+	case *image.Gray:
+		return tiled(temp) + result
+	case *image.Gray16:
+		return tiled(2*temp) + 2*result
+	case *image.YCbCr:
+		// Interleaved copy of the source, temporary image and result
+		// and the converted result for nearest-neighbor. Filtered
+		// images are resized plane by plane, which needs less.
+		return 3*src + tiled(3*temp) + 3*result + 3*result
+	case *image.Paletted:
+		// Expanded copy of the source for the PaletteMode conversions.
+		return 4*src + tiled(8*temp) + 8*result
+	case *image.NYCbCrA:
+		// 16-bit copies of the color planes weighted by alpha and of
+		// the alpha, with the chroma at most at full resolution, and
+		// the temporary images and results of each plane.
+		return 13*src + tiled(temp) + 6*tiled(2*temp) + 16*result
+	default:
+		return tiled(8*temp) + 8*result
+	}
+}
@@ -0,0 +1,48 @@
+package resize
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func Test_Limits(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 100, 100))
+
+	var limitTests = []struct {
+		limits Limits
+		width  uint
+		height uint
+		interp InterpolationFunction
+		limit  string
+	}{
+		{Limits{MaxSourcePixels: 9999}, 10, 10, Bilinear, "MaxSourcePixels"},
+		{Limits{MaxOutputPixels: 1 << 20}, 1 << 20, 0, Bilinear, "MaxOutputPixels"},
+		{Limits{MaxMemory: 1 << 20}, 1000, 1000, Bilinear, "MaxMemory"},
+		{Limits{10000, 10000, 10000}, 10, 10, Bilinear, ""},
+		// The weights of a wide filter are counted.
+		{Limits{MaxMemory: 1 << 20}, 10, 10, &Filter{1 << 16, linear}, "MaxMemory"},
+	}
+	for i, tt := range limitTests {
+		_, err := ResizeWithOptions(tt.width, tt.height, src, tt.interp, &Options{Limits: tt.limits})
+		if tt.limit == "" {
+			if err != nil {
+				t.Errorf("%d. unexpected error %v", i, err)
+			}
+			continue
+		}
+		var le *LimitError
+		if !errors.As(err, &le) || le.Limit != tt.limit || !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%d. got error %v, want %s exceeded", i, err, tt.limit)
+		}
+	}
+}
+
+func Test_ThumbnailLimits(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 100, 100))
+	opts := &Options{Limits: Limits{MaxSourcePixels: 100}}
+	// The source is checked even if it is returned unchanged.
+	if _, err := ThumbnailWithOptions(200, 200, src, Bilinear, opts); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got error %v, want ErrLimitExceeded", err)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"image"
+	"image/draw"
+)
+
+// A Mode selects how ResizeMode fits an image into the target size.
+type Mode int
+
+const (
+	// Stretch scales to the target size, ignoring the aspect ratio.
+	Stretch Mode = iota
+	// Fit scales to the largest size that fits into the target size,
+	// preserving the aspect ratio. One side of the result may be shorter.
+	Fit
+	// Fill scales to the smallest size that covers the target size,
+	// preserving the aspect ratio, and crops the overflow at the anchor.
+	Fill
+	// Pad fits the image like Fit and places it at the anchor onto a
+	// canvas of the target size filled with the background color.
+	Pad
+)
+
+// Cover is another name for Fill.
+const Cover = Fill
+
+// An Anchor selects the part of the image kept by Fill and the position of
+// the image on the canvas of Pad.
+type Anchor int
+
+// The anchors, Center by default.
+const (
+	Center Anchor = iota
+	Top
+	Bottom
+	Left
+	Right
+	TopLeft
+	TopRight
+	BottomLeft
+	BottomRight
+)
+
+// factors returns the fraction of the free space left and above of the
+// anchored area.
+func (a Anchor) factors() (fx, fy float64) {
+	fx, fy = 0.5, 0.5
+	switch a {
+	case Left, TopLeft, BottomLeft:
+		fx = 0
+	case Right, TopRight, BottomRight:
+		fx = 1
+	}
+	switch a {
+	case Top, TopLeft, TopRight:
+		fy = 0
+	case Bottom, BottomLeft, BottomRight:
+		fy = 1
+	}
+	return
+}
+
+// ResizeMode scales img to width and height as selected by mode, using the
+// interpolation function interp. opts.Anchor positions the image for Fill
+// and Pad, opts.Background is the color of the padding. Both width and
+// height must not be 0. Unlike Thumbnail, images smaller than the target
+// size are scaled up.
+func ResizeMode(mode Mode, width, height uint, img image.Image, interp InterpolationFunction, opts *Options) (image.Image, error) {
+	return newResizer(interp, opts).ResizeMode(mode, width, height, img)
+}
+
+// ResizeMode scales img to width and height as selected by mode, see the
+// package level ResizeMode.
+func (r *Resizer) ResizeMode(mode Mode, width, height uint, img image.Image) (image.Image, error) {
+	if err := r.opts.validate(); err != nil {
+		return nil, err
+	}
+	if width == 0 || height == 0 {
+		return nil, ErrInvalidSize
+	}
+	if err := r.opts.Limits.checkSource(img.Bounds()); err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, ErrEmptyImage
+	}
+
+	switch mode {
+	case Fit:
+		w, h := fitSize(width, height, b)
+		return r.Resize(w, h, img)
+	case Fill:
+		return r.ResizeRegion(width, height, img, fillRegion(width, height, b, r.opts.Anchor))
+	case Pad:
+		return r.pad(width, height, img)
+	default:
+		return r.Resize(width, height, img)
+	}
+}
+
+// fitSize returns the largest size with the aspect ratio of b that fits
+// into width and height.
+func fitSize(width, height uint, b image.Rectangle) (uint, uint) {
+	dx, dy := uint64(b.Dx()), uint64(b.Dy())
+	w, h := uint64(width), uint64(height)
+	if dx*h > dy*w {
+		// Wider than the target, rounded to the nearest pixel.
+		h = (2*dy*w + dx) / (2 * dx)
+	} else {
+		w = (2*dx*h + dy) / (2 * dy)
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return uint(w), uint(h)
+}
+
+// fillRegion returns the largest region of b with the aspect ratio of
+// width and height, positioned at anchor.
+func fillRegion(width, height uint, b image.Rectangle, anchor Anchor) Region {
+	g := RectRegion(b)
+	fx, fy := anchor.factors()
+	dx, dy := float64(b.Dx()), float64(b.Dy())
+	if dx*float64(height) > dy*float64(width) {
+		// Wider than the target, crop left and right.
+		w := dy * float64(width) / float64(height)
+		g.X0 += (dx - w) * fx
+		g.X1 = g.X0 + w
+	} else {
+		h := dx * float64(height) / float64(width)
+		g.Y0 += (dy - h) * fy
+		g.Y1 = g.Y0 + h
+	}
+	return g
+}
+
+// pad fits img into width and height and draws it onto a canvas filled
+// with the background color.
+func (r *Resizer) pad(width, height uint, img image.Image) (image.Image, error) {
+	w, h := fitSize(width, height, img.Bounds())
+	if err := checkSize(width, height, img.Bounds()); err != nil {
+		return nil, err
+	}
+	scaleX, scaleY := float64(img.Bounds().Dx())/float64(w), float64(img.Bounds().Dy())/float64(h)
+	if err := r.checkLimits(width, height, scaleX, scaleY, img); err != nil {
+		return nil, err
+	}
+	canvas := newCanvas(img, image.Rect(0, 0, int(width), int(height)))
+	if r.opts.Background != nil {
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(r.opts.Background), image.Point{}, draw.Src)
+	}
+
+	fx, fy := r.opts.Anchor.factors()
+	x := int(float64(width-w) * fx)
+	y := int(float64(height-h) * fy)
+	rect := image.Rect(x, y, x+int(w), y+int(h))
+	if err := r.ResizeInto(canvas.SubImage(rect).(draw.Image), img); err != nil {
+		return nil, err
+	}
+	return canvas, nil
+}
+
+type drawImageWithSubImage interface {
+	draw.Image
+	SubImage(image.Rectangle) image.Image
+}
+
+// newCanvas returns an image of the type Resize returns for img, or an
+// *image.RGBA for the YCbCr types.
+func newCanvas(img image.Image, rect image.Rectangle) drawImageWithSubImage {
+	switch img.(type) {
+	case *image.RGBA, *image.YCbCr, *image.NYCbCrA:
+		return image.NewRGBA(rect)
+	case *image.NRGBA:
+		return image.NewNRGBA(rect)
+	case *image.RGBA64:
+		return image.NewRGBA64(rect)
+	case *image.NRGBA64:
+		return image.NewNRGBA64(rect)
+	case *image.Gray:
+		return image.NewGray(rect)
+	case *image.Gray16:
+		return image.NewGray16(rect)
+	default:
+		return image.NewRGBA64(rect)
+	}
+}
@@ -0,0 +1,115 @@
+package resize
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func Test_FitSize(t *testing.T) {
+	for _, c := range []struct {
+		b             image.Rectangle
+		width, height uint
+		w, h          uint
+	}{
+		{image.Rect(0, 0, 200, 100), 50, 50, 50, 25},
+		{image.Rect(0, 0, 100, 200), 50, 50, 25, 50},
+		{image.Rect(0, 0, 3, 2), 100, 100, 100, 67},
+		{image.Rect(0, 0, 20, 10), 100, 100, 100, 50},
+		{image.Rect(0, 0, 1000, 1), 10, 10, 10, 1},
+	} {
+		if w, h := fitSize(c.width, c.height, c.b); w != c.w || h != c.h {
+			t.Errorf("fit %v into %dx%d: got %dx%d, want %dx%d", c.b, c.width, c.height, w, h, c.w, c.h)
+		}
+	}
+}
+
+// twoTone returns a gray image, dark in the left and light in the right half.
+func twoTone() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			if x < 100 {
+				img.Pix[y*img.Stride+x] = 0x20
+			} else {
+				img.Pix[y*img.Stride+x] = 0xe0
+			}
+		}
+	}
+	return img
+}
+
+func Test_ModeFill(t *testing.T) {
+	img := twoTone()
+	for _, c := range []struct {
+		anchor Anchor
+		x      int
+		want   uint8
+	}{
+		{Left, 0, 0x20},
+		{TopLeft, 0, 0x20},
+		{Right, 49, 0xe0},
+		{Center, 0, 0x20},
+		{Center, 49, 0xe0},
+	} {
+		m, err := ResizeMode(Fill, 50, 50, img, Bilinear, &Options{Anchor: c.anchor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Bounds() != image.Rect(0, 0, 50, 50) {
+			t.Fatalf("got bounds %v, want %v", m.Bounds(), image.Rect(0, 0, 50, 50))
+		}
+		if v := m.(*image.Gray).GrayAt(c.x, 25).Y; v != c.want {
+			t.Errorf("anchor %d: got %#x at x=%d, want %#x", c.anchor, v, c.x, c.want)
+		}
+	}
+	// The center crop doesn't contain the outer quarters.
+	m, _ := ResizeMode(Cover, 50, 50, img, Bilinear, nil)
+	if v := m.(*image.Gray).GrayAt(24, 25).Y; v == 0x20 || v == 0xe0 {
+		t.Errorf("got %#x in the center, want a mix of both halves", v)
+	}
+}
+
+func Test_ModePad(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	for i := range img.Pix {
+		img.Pix[i] = 0x40
+	}
+	opts := &Options{Background: color.White}
+	m, err := ResizeMode(Pad, 50, 50, img, Bilinear, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray := m.(*image.Gray)
+	if gray.Bounds() != image.Rect(0, 0, 50, 50) {
+		t.Fatalf("got bounds %v, want %v", gray.Bounds(), image.Rect(0, 0, 50, 50))
+	}
+	for _, c := range []struct {
+		y    int
+		want uint8
+	}{{0, 0xff}, {11, 0xff}, {12, 0x40}, {36, 0x40}, {37, 0xff}, {49, 0xff}} {
+		if v := gray.GrayAt(25, c.y).Y; v != c.want {
+			t.Errorf("y=%d: got %#x, want %#x", c.y, v, c.want)
+		}
+	}
+
+	opts.Anchor = BottomRight
+	m, _ = ResizeMode(Pad, 50, 50, img, Bilinear, opts)
+	if v := m.(*image.Gray).GrayAt(25, 49).Y; v != 0x40 {
+		t.Errorf("got %#x at the bottom, want %#x", v, 0x40)
+	}
+}
+
+func Test_ModeStretch(t *testing.T) {
+	img := twoTone()
+	m, err := ResizeMode(Stretch, 30, 70, img, Bilinear, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != image.Rect(0, 0, 30, 70) {
+		t.Errorf("got bounds %v, want %v", m.Bounds(), image.Rect(0, 0, 30, 70))
+	}
+	if _, err := ResizeMode(Fit, 0, 70, img, Bilinear, nil); err != ErrInvalidSize {
+		t.Errorf("got error %v, want %v", err, ErrInvalidSize)
+	}
+}
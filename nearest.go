@@ -34,7 +34,7 @@ func floatToUint16(x float32) uint16 {
 	return uint16(x)
 }
 
-func nearestGeneric(in image.Image, out *image.RGBA64, scale float64, coeffs []bool, offset []int, filterLength int) {
+func nearestGeneric(in image.Image, out *image.RGBA64, scale float64, coeffs []bool, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -47,11 +47,11 @@ func nearestGeneric(in image.Image, out *image.RGBA64, scale float64, coeffs []b
 			for i := 0; i < filterLength; i++ {
 				if coeffs[ci+i] {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = maxX
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum++
+							continue
+						}
 					}
 					r, g, b, a := in.At(xi+in.Bounds().Min.X, x+in.Bounds().Min.Y).RGBA()
 					rgba[0] += float32(r)
@@ -79,7 +79,7 @@ func nearestGeneric(in image.Image, out *image.RGBA64, scale float64, coeffs []b
 	}
 }
 
-func nearestRGBA(in *image.RGBA, out *image.RGBA, scale float64, coeffs []bool, offset []int, filterLength int) {
+func nearestRGBA(in *image.RGBA, out *image.RGBA, scale float64, coeffs []bool, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -93,14 +93,13 @@ func nearestRGBA(in *image.RGBA, out *image.RGBA, scale float64, coeffs []bool,
 			for i := 0; i < filterLength; i++ {
 				if coeffs[ci+i] {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = 4 * maxX
-					default:
-						xi *= 4
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum++
+							continue
+						}
 					}
+					xi *= 4
 					rgba[0] += float32(row[xi+0])
 					rgba[1] += float32(row[xi+1])
 					rgba[2] += float32(row[xi+2])
@@ -118,7 +117,7 @@ func nearestRGBA(in *image.RGBA, out *image.RGBA, scale float64, coeffs []bool,
 	}
 }
 
-func nearestRGBA64(in *image.RGBA64, out *image.RGBA64, scale float64, coeffs []bool, offset []int, filterLength int) {
+func nearestRGBA64(in *image.RGBA64, out *image.RGBA64, scale float64, coeffs []bool, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -132,14 +131,13 @@ func nearestRGBA64(in *image.RGBA64, out *image.RGBA64, scale float64, coeffs []
 			for i := 0; i < filterLength; i++ {
 				if coeffs[ci+i] {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = 8 * maxX
-					default:
-						xi *= 8
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum++
+							continue
+						}
 					}
+					xi *= 8
 					rgba[0] += float32(uint16(row[xi+0])<<8 | uint16(row[xi+1]))
 					rgba[1] += float32(uint16(row[xi+2])<<8 | uint16(row[xi+3]))
 					rgba[2] += float32(uint16(row[xi+4])<<8 | uint16(row[xi+5]))
@@ -165,7 +163,7 @@ func nearestRGBA64(in *image.RGBA64, out *image.RGBA64, scale float64, coeffs []
 	}
 }
 
-func nearestGray(in *image.Gray, out *image.Gray, scale float64, coeffs []bool, offset []int, filterLength int) {
+func nearestGray(in *image.Gray, out *image.Gray, scale float64, coeffs []bool, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -179,11 +177,11 @@ func nearestGray(in *image.Gray, out *image.Gray, scale float64, coeffs []bool,
 			for i := 0; i < filterLength; i++ {
 				if coeffs[ci+i] {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = maxX
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum++
+							continue
+						}
 					}
 					gray += float32(row[xi])
 					sum++
@@ -196,7 +194,7 @@ func nearestGray(in *image.Gray, out *image.Gray, scale float64, coeffs []bool,
 	}
 }
 
-func nearestGray16(in *image.Gray16, out *image.Gray16, scale float64, coeffs []bool, offset []int, filterLength int) {
+func nearestGray16(in *image.Gray16, out *image.Gray16, scale float64, coeffs []bool, offset []int, filterLength int, edge Edge) {
 	newBounds := out.Bounds()
 	maxX := in.Bounds().Dx() - 1
 
@@ -210,14 +208,13 @@ func nearestGray16(in *image.Gray16, out *image.Gray16, scale float64, coeffs []
 			for i := 0; i < filterLength; i++ {
 				if coeffs[ci+i] {
 					xi := start + i
-					switch {
-					case xi < 0:
-						xi = 0
-					case xi >= maxX:
-						xi = 2 * maxX
-					default:
-						xi *= 2
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum++
+							continue
+						}
 					}
+					xi *= 2
 					gray += float32(uint16(row[xi+0])<<8 | uint16(row[xi+1]))
 					sum++
 				}
@@ -230,3 +227,108 @@ func nearestGray16(in *image.Gray16, out *image.Gray16, scale float64, coeffs []
 		}
 	}
 }
+
+func nearestNRGBA(in *image.NRGBA, out *image.NRGBA, scale float64, coeffs []bool, offset []int, filterLength int, edge Edge) {
+	newBounds := out.Bounds()
+	maxX := in.Bounds().Dx() - 1
+
+	for x := newBounds.Min.X; x < newBounds.Max.X; x++ {
+		row := in.Pix[x*in.Stride:]
+		for y := newBounds.Min.Y; y < newBounds.Max.Y; y++ {
+			// Color samples are weighted by alpha so that fully
+			// transparent pixels don't bleed into the result.
+			var rgb [3]float32
+			var alpha float32
+			var sum float32
+			start := offset[y]
+			ci := y * filterLength
+			for i := 0; i < filterLength; i++ {
+				if coeffs[ci+i] {
+					xi := start + i
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum++
+							continue
+						}
+					}
+					xi *= 4
+					a := float32(row[xi+3])
+					rgb[0] += float32(row[xi+0]) * a
+					rgb[1] += float32(row[xi+1]) * a
+					rgb[2] += float32(row[xi+2]) * a
+					alpha += a
+					sum++
+				}
+			}
+
+			xo := (y-newBounds.Min.Y)*out.Stride + (x-newBounds.Min.X)*4
+			if alpha == 0 {
+				out.Pix[xo+0] = 0
+				out.Pix[xo+1] = 0
+				out.Pix[xo+2] = 0
+				out.Pix[xo+3] = 0
+				continue
+			}
+			out.Pix[xo+0] = floatToUint8(rgb[0] / alpha)
+			out.Pix[xo+1] = floatToUint8(rgb[1] / alpha)
+			out.Pix[xo+2] = floatToUint8(rgb[2] / alpha)
+			out.Pix[xo+3] = floatToUint8(alpha / sum)
+		}
+	}
+}
+
+func nearestNRGBA64(in *image.NRGBA64, out *image.NRGBA64, scale float64, coeffs []bool, offset []int, filterLength int, edge Edge) {
+	newBounds := out.Bounds()
+	maxX := in.Bounds().Dx() - 1
+
+	for x := newBounds.Min.X; x < newBounds.Max.X; x++ {
+		row := in.Pix[x*in.Stride:]
+		for y := newBounds.Min.Y; y < newBounds.Max.Y; y++ {
+			// Color samples are weighted by alpha so that fully
+			// transparent pixels don't bleed into the result.
+			var rgb [3]float64
+			var alpha float64
+			var sum float64
+			start := offset[y]
+			ci := y * filterLength
+			for i := 0; i < filterLength; i++ {
+				if coeffs[ci+i] {
+					xi := start + i
+					if uint(xi) > uint(maxX) {
+						if xi = edge.index(xi, maxX); xi < 0 {
+							sum++
+							continue
+						}
+					}
+					xi *= 8
+					a := float64(uint16(row[xi+6])<<8 | uint16(row[xi+7]))
+					rgb[0] += float64(uint16(row[xi+0])<<8|uint16(row[xi+1])) * a
+					rgb[1] += float64(uint16(row[xi+2])<<8|uint16(row[xi+3])) * a
+					rgb[2] += float64(uint16(row[xi+4])<<8|uint16(row[xi+5])) * a
+					alpha += a
+					sum++
+				}
+			}
+
+			xo := (y-newBounds.Min.Y)*out.Stride + (x-newBounds.Min.X)*8
+			if alpha == 0 {
+				for i := 0; i < 8; i++ {
+					out.Pix[xo+i] = 0
+				}
+				continue
+			}
+			value := floatToUint16(float32(rgb[0] / alpha))
+			out.Pix[xo+0] = uint8(value >> 8)
+			out.Pix[xo+1] = uint8(value)
+			value = floatToUint16(float32(rgb[1] / alpha))
+			out.Pix[xo+2] = uint8(value >> 8)
+			out.Pix[xo+3] = uint8(value)
+			value = floatToUint16(float32(rgb[2] / alpha))
+			out.Pix[xo+4] = uint8(value >> 8)
+			out.Pix[xo+5] = uint8(value)
+			value = floatToUint16(float32(alpha / sum))
+			out.Pix[xo+6] = uint8(value >> 8)
+			out.Pix[xo+7] = uint8(value)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// A PaletteMode selects how *image.Paletted images are resized.
+type PaletteMode int
+
+const (
+	// PaletteGeneric resizes paletted images like other images without a
+	// fast path. The result is an *image.RGBA64.
+	PaletteGeneric PaletteMode = iota
+	// PaletteRGBA expands the palette before resizing. The result is an
+	// *image.RGBA.
+	PaletteRGBA
+	// PaletteNRGBA expands the palette before resizing. The result is an
+	// *image.NRGBA.
+	PaletteNRGBA
+	// PaletteOriginal re-quantizes the result to the palette of the
+	// source. The result is an *image.Paletted.
+	PaletteOriginal
+	// PaletteAdaptive re-quantizes the result to a new palette of up to
+	// as many colors as the palette of the source, computed with the
+	// median cut algorithm. The result is an *image.Paletted.
+	PaletteAdaptive
+)
+
+// resizePaletted resizes img like resize, converting it as selected by
+// the PaletteMode of the options.
+func (r *Resizer) resizePaletted(ctx context.Context, width, height uint, scaleX, scaleY, x0, y0 float64, img *image.Paletted) (image.Image, error) {
+	src := expandPaletted(img, r.opts.Palette == PaletteRGBA)
+	var m image.Image
+	var err error
+	if r.opts.Linear {
+		m, err = r.resizeLinear(ctx, width, height, scaleX, scaleY, x0, y0, src)
+	} else {
+		m, err = r.resize(ctx, width, height, scaleX, scaleY, x0, y0, src, nil)
+	}
+	if err != nil || r.opts.Palette == PaletteRGBA || r.opts.Palette == PaletteNRGBA {
+		return m, err
+	}
+
+	palette := img.Palette
+	if r.opts.Palette == PaletteAdaptive || len(palette) == 0 {
+		n := len(palette)
+		if n == 0 || n > 256 {
+			n = 256
+		}
+		palette = medianCut(m.(*image.NRGBA), n)
+	}
+	out := image.NewPaletted(m.Bounds(), palette)
+	var drawer draw.Drawer = draw.Src
+	if r.opts.Dither {
+		drawer = draw.FloydSteinberg
+	}
+	drawer.Draw(out, out.Bounds(), m, image.Point{})
+	return out, nil
+}
+
+// expandPaletted converts m to an *image.RGBA if premultiplied is set,
+// an *image.NRGBA otherwise, with the bounds translated to the origin.
+// Indices outside of the palette are transparent.
+func expandPaletted(m *image.Paletted, premultiplied bool) image.Image {
+	var lut [256][4]uint8
+	for i, c := range m.Palette {
+		if i >= len(lut) {
+			break
+		}
+		if premultiplied {
+			p := color.RGBAModel.Convert(c).(color.RGBA)
+			lut[i] = [4]uint8{p.R, p.G, p.B, p.A}
+		} else {
+			p := color.NRGBAModel.Convert(c).(color.NRGBA)
+			lut[i] = [4]uint8{p.R, p.G, p.B, p.A}
+		}
+	}
+
+	b := m.Bounds()
+	rect := image.Rect(0, 0, b.Dx(), b.Dy())
+	pix := make([]uint8, 4*b.Dx()*b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		row := m.Pix[y*m.Stride:]
+		dst := pix[y*4*b.Dx():]
+		for x := 0; x < b.Dx(); x++ {
+			copy(dst[x*4:x*4+4], lut[row[x]][:])
+		}
+	}
+	if premultiplied {
+		return &image.RGBA{Pix: pix, Stride: 4 * b.Dx(), Rect: rect}
+	}
+	return &image.NRGBA{Pix: pix, Stride: 4 * b.Dx(), Rect: rect}
+}
+
+// A histEntry is a color and the number of pixels with that color.
+type histEntry struct {
+	c [4]uint8
+	n int
+}
+
+// medianCut returns a palette of at most n colors for the pixels of m,
+// computed with the median cut algorithm. Fully transparent pixels are
+// mapped to a single transparent entry.
+func medianCut(m *image.NRGBA, n int) color.Palette {
+	hist := make(map[[4]uint8]int)
+	transparent := false
+	b := m.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := m.Pix[m.PixOffset(b.Min.X, y):]
+		for x := 0; x < b.Dx(); x++ {
+			var c [4]uint8
+			copy(c[:], row[x*4:x*4+4])
+			if c[3] == 0 {
+				transparent = true
+				continue
+			}
+			hist[c]++
+		}
+	}
+
+	var palette color.Palette
+	if transparent {
+		palette = append(palette, color.NRGBA{})
+		n--
+	}
+	if len(hist) == 0 || n < 1 {
+		return palette
+	}
+	colors := make([]histEntry, 0, len(hist))
+	for c, count := range hist {
+		colors = append(colors, histEntry{c, count})
+	}
+
+	// Split the box with the widest channel at the median of its pixels
+	// until there are n boxes or all boxes hold a single color.
+	boxes := [][]histEntry{colors}
+	for len(boxes) < n {
+		best, axis, width := -1, 0, 0
+		for i, box := range boxes {
+			if a, w := widestChannel(box); w > width {
+				best, axis, width = i, a, w
+			}
+		}
+		if best < 0 {
+			break
+		}
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool { return box[i].c[axis] < box[j].c[axis] })
+		total := 0
+		for _, e := range box {
+			total += e.n
+		}
+		k, sum := 1, box[0].n
+		for k < len(box)-1 && 2*sum < total {
+			sum += box[k].n
+			k++
+		}
+		boxes[best] = box[:k]
+		boxes = append(boxes, box[k:])
+	}
+
+	for _, box := range boxes {
+		var sum [4]int
+		total := 0
+		for _, e := range box {
+			for i := range sum {
+				sum[i] += int(e.c[i]) * e.n
+			}
+			total += e.n
+		}
+		palette = append(palette, color.NRGBA{
+			uint8((sum[0] + total/2) / total),
+			uint8((sum[1] + total/2) / total),
+			uint8((sum[2] + total/2) / total),
+			uint8((sum[3] + total/2) / total),
+		})
+	}
+	return palette
+}
+
+// widestChannel returns the channel with the largest range of values in
+// box and that range.
+func widestChannel(box []histEntry) (axis, width int) {
+	lo := [4]uint8{255, 255, 255, 255}
+	var hi [4]uint8
+	for _, e := range box {
+		for i, v := range e.c {
+			if v < lo[i] {
+				lo[i] = v
+			}
+			if v > hi[i] {
+				hi[i] = v
+			}
+		}
+	}
+	for i := range lo {
+		if w := int(hi[i]) - int(lo[i]); w > width {
+			axis, width = i, w
+		}
+	}
+	return
+}
@@ -0,0 +1,90 @@
+package resize
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var blackWhite = color.Palette{color.Black, color.White}
+
+// checkerboard returns a paletted image with alternating palette indices.
+func checkerboard(palette color.Palette) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, 64, 64), palette)
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetColorIndex(x, y, uint8((x+y)%2))
+		}
+	}
+	return img
+}
+
+func Test_PalettedTypes(t *testing.T) {
+	img := checkerboard(blackWhite)
+	for _, c := range []struct {
+		mode PaletteMode
+		want string
+	}{
+		{PaletteGeneric, "*image.RGBA64"},
+		{PaletteRGBA, "*image.RGBA"},
+		{PaletteNRGBA, "*image.NRGBA"},
+		{PaletteOriginal, "*image.Paletted"},
+		{PaletteAdaptive, "*image.Paletted"},
+	} {
+		m, err := ResizeWithOptions(20, 20, img, Bilinear, &Options{Palette: c.mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprintf("%T", m); got != c.want {
+			t.Errorf("mode %d: got %s, want %s", c.mode, got, c.want)
+		}
+	}
+}
+
+func Test_PalettedDither(t *testing.T) {
+	// A checkerboard averages to gray, which is dithered to both colors.
+	img := checkerboard(blackWhite)
+	for _, dither := range []bool{false, true} {
+		m, err := ResizeWithOptions(32, 32, img, Bilinear, &Options{Palette: PaletteOriginal, Dither: dither})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := m.(*image.Paletted)
+		if len(p.Palette) != 2 {
+			t.Fatalf("got %d colors, want the source palette", len(p.Palette))
+		}
+		var count [2]int
+		for _, i := range p.Pix {
+			count[i]++
+		}
+		if mixed := count[0] > 100 && count[1] > 100; mixed != dither {
+			t.Errorf("dither %v: got %v pixels of each color", dither, count)
+		}
+	}
+}
+
+func Test_MedianCut(t *testing.T) {
+	colors := []color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {10, 20, 30, 128}}
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < 64; i++ {
+		img.Set(i%8, i/8, colors[i%4])
+	}
+	img.Set(0, 0, color.NRGBA{})
+
+	palette := medianCut(img, 5)
+	if len(palette) != 5 {
+		t.Fatalf("got %d colors, want 5", len(palette))
+	}
+	if palette[0] != (color.NRGBA{}) {
+		t.Errorf("got %v, want a transparent entry first", palette[0])
+	}
+	for _, c := range colors {
+		if palette[palette.Index(c)] != c {
+			t.Errorf("%v is missing from the palette %v", c, palette)
+		}
+	}
+	if palette = medianCut(img, 3); len(palette) != 3 {
+		t.Errorf("got %d colors, want 3", len(palette))
+	}
+}
@@ -0,0 +1,319 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import "image"
+
+// A pipeline describes how images of one concrete type are resized:
+// the types of the temporary and the result image and the converters
+// used for the horizontal and the vertical pass.
+type pipeline struct {
+	precision int  // 8, 16 or 0 for nearest-neighbor
+	bpp       int  // bytes per pixel of the temporary image
+	opaque    bool // no alpha channel, see EdgeTransparent
+
+	// prepare converts the input before the horizontal pass, may be nil.
+	prepare func(in image.Image) image.Image
+	// temp returns the transposed temporary image backed by pix.
+	temp func(pix []uint8, rect image.Rectangle, in image.Image) imageWithSubImage
+	// result returns dst if it can hold the result, or a new image.
+	result func(rect image.Rectangle, in, dst image.Image) imageWithSubImage
+	// horizontal and vertical resize in into the slice out.
+	horizontal, vertical func(in, out image.Image, w *weights, edge Edge)
+	// finish converts the result to the returned image, may be nil.
+	finish func(result image.Image) image.Image
+}
+
+// pipelineFor returns the pipeline for the concrete type of img.
+// Generic access to image.Image is slow in tight loops.
+// The optimal access has to be determined from the concrete image type.
+func pipelineFor(img image.Image, nearest bool) *pipeline {
+	switch img.(type) {
+	case *image.RGBA:
+		if nearest {
+			return &nearestRGBAPipeline
+		}
+		return &rgbaPipeline
+	case *image.NRGBA:
+		if nearest {
+			return &nearestNRGBAPipeline
+		}
+		return &nrgbaPipeline
+	case *image.YCbCr:
+		// Only used for nearest-neighbor, filtered images are resized
+		// plane by plane by resizePlanar.
+		return &nearestYCbCrPipeline
+	case *image.RGBA64:
+		if nearest {
+			return &nearestRGBA64Pipeline
+		}
+		return &rgba64Pipeline
+	case *image.NRGBA64:
+		if nearest {
+			return &nearestNRGBA64Pipeline
+		}
+		return &nrgba64Pipeline
+	case *image.Gray:
+		if nearest {
+			return &nearestGrayPipeline
+		}
+		return &grayPipeline
+	case *image.Gray16:
+		if nearest {
+			return &nearestGray16Pipeline
+		}
+		return &gray16Pipeline
+	default:
+		if nearest {
+			return &nearestGenericPipeline
+		}
+		return &genericPipeline
+	}
+}
+
+var (
+	// 8-bit precision
+	rgbaPipeline = pipeline{
+		precision: 8, bpp: 4, temp: tempRGBA, result: resultRGBA,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			resizeRGBA(in.(*image.RGBA), out.(*image.RGBA), w.scale, w.coeffs8, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			resizeRGBA(in.(*image.RGBA), out.(*image.RGBA), w.scale, w.coeffs8, w.offset, w.filterLength, edge)
+		},
+	}
+	// 16-bit precision, the temporary image holds premultiplied values.
+	nrgbaPipeline = pipeline{
+		precision: 16, bpp: 8, temp: tempRGBA64, result: resultNRGBA,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			resizeNRGBA(in.(*image.NRGBA), out.(*image.RGBA64), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			resizeRGBA64ToNRGBA(in.(*image.RGBA64), out.(*image.NRGBA), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+	}
+	// 16-bit precision
+	rgba64Pipeline = pipeline{
+		precision: 16, bpp: 8, temp: tempRGBA64, result: resultRGBA64,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			resizeRGBA64(in.(*image.RGBA64), out.(*image.RGBA64), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			resizeRGBA64(in.(*image.RGBA64), out.(*image.RGBA64), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+	}
+	// 16-bit precision, the temporary image holds premultiplied values.
+	nrgba64Pipeline = pipeline{
+		precision: 16, bpp: 8, temp: tempRGBA64, result: resultNRGBA64,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			resizeNRGBA64(in.(*image.NRGBA64), out.(*image.RGBA64), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			resizeRGBA64ToNRGBA64(in.(*image.RGBA64), out.(*image.NRGBA64), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+	}
+	// 8-bit precision
+	grayPipeline = pipeline{
+		precision: 8, bpp: 1, opaque: true, temp: tempGray, result: resultGray,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			resizeGray(in.(*image.Gray), out.(*image.Gray), w.scale, w.coeffs8, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			resizeGray(in.(*image.Gray), out.(*image.Gray), w.scale, w.coeffs8, w.offset, w.filterLength, edge)
+		},
+	}
+	// 16-bit precision
+	gray16Pipeline = pipeline{
+		precision: 16, bpp: 2, opaque: true, temp: tempGray16, result: resultGray16,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			resizeGray16(in.(*image.Gray16), out.(*image.Gray16), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			resizeGray16(in.(*image.Gray16), out.(*image.Gray16), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+	}
+	// 16-bit precision
+	genericPipeline = pipeline{
+		precision: 16, bpp: 8, temp: tempRGBA64, result: resultRGBA64,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			resizeGeneric(in, out.(*image.RGBA64), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			resizeRGBA64(in.(*image.RGBA64), out.(*image.RGBA64), w.scale, w.coeffs16, w.offset, w.filterLength, edge)
+		},
+	}
+)
+
+var (
+	nearestRGBAPipeline = pipeline{
+		bpp: 4, temp: tempRGBA, result: resultRGBA,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			nearestRGBA(in.(*image.RGBA), out.(*image.RGBA), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			nearestRGBA(in.(*image.RGBA), out.(*image.RGBA), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+	}
+	nearestNRGBAPipeline = pipeline{
+		bpp: 4, temp: tempNRGBA, result: resultNRGBA,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			nearestNRGBA(in.(*image.NRGBA), out.(*image.NRGBA), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			nearestNRGBA(in.(*image.NRGBA), out.(*image.NRGBA), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+	}
+	// accessing the YCbCr arrays in a tight loop is slow.
+	// converting the image to ycc increases performance by 2x.
+	nearestYCbCrPipeline = pipeline{
+		bpp: 3, opaque: true, prepare: prepareYCC, temp: tempYCC, result: resultYCC,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			nearestYCbCr(in.(*ycc), out.(*ycc), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			nearestYCbCr(in.(*ycc), out.(*ycc), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+		finish: finishYCC,
+	}
+	nearestRGBA64Pipeline = pipeline{
+		bpp: 8, temp: tempRGBA64, result: resultRGBA64,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			nearestRGBA64(in.(*image.RGBA64), out.(*image.RGBA64), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			nearestRGBA64(in.(*image.RGBA64), out.(*image.RGBA64), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+	}
+	nearestNRGBA64Pipeline = pipeline{
+		bpp: 8, temp: tempNRGBA64, result: resultNRGBA64,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			nearestNRGBA64(in.(*image.NRGBA64), out.(*image.NRGBA64), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			nearestNRGBA64(in.(*image.NRGBA64), out.(*image.NRGBA64), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+	}
+	nearestGrayPipeline = pipeline{
+		bpp: 1, opaque: true, temp: tempGray, result: resultGray,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			nearestGray(in.(*image.Gray), out.(*image.Gray), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			nearestGray(in.(*image.Gray), out.(*image.Gray), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+	}
+	nearestGray16Pipeline = pipeline{
+		bpp: 2, opaque: true, temp: tempGray16, result: resultGray16,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			nearestGray16(in.(*image.Gray16), out.(*image.Gray16), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			nearestGray16(in.(*image.Gray16), out.(*image.Gray16), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+	}
+	nearestGenericPipeline = pipeline{
+		bpp: 8, temp: tempRGBA64, result: resultRGBA64,
+		horizontal: func(in, out image.Image, w *weights, edge Edge) {
+			nearestGeneric(in, out.(*image.RGBA64), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+		vertical: func(in, out image.Image, w *weights, edge Edge) {
+			nearestRGBA64(in.(*image.RGBA64), out.(*image.RGBA64), w.scale, w.nearest, w.offset, w.filterLength, edge)
+		},
+	}
+)
+
+func prepareYCC(in image.Image) image.Image {
+	return imageYCbCrToYCC(in.(*image.YCbCr))
+}
+
+func finishYCC(result image.Image) image.Image {
+	return result.(*ycc).YCbCr()
+}
+
+func tempRGBA(pix []uint8, rect image.Rectangle, _ image.Image) imageWithSubImage {
+	return &image.RGBA{Pix: pix, Stride: 4 * rect.Dx(), Rect: rect}
+}
+
+func tempRGBA64(pix []uint8, rect image.Rectangle, _ image.Image) imageWithSubImage {
+	return &image.RGBA64{Pix: pix, Stride: 8 * rect.Dx(), Rect: rect}
+}
+
+func tempNRGBA(pix []uint8, rect image.Rectangle, _ image.Image) imageWithSubImage {
+	return &image.NRGBA{Pix: pix, Stride: 4 * rect.Dx(), Rect: rect}
+}
+
+func tempNRGBA64(pix []uint8, rect image.Rectangle, _ image.Image) imageWithSubImage {
+	return &image.NRGBA64{Pix: pix, Stride: 8 * rect.Dx(), Rect: rect}
+}
+
+func tempGray(pix []uint8, rect image.Rectangle, _ image.Image) imageWithSubImage {
+	return &image.Gray{Pix: pix, Stride: rect.Dx(), Rect: rect}
+}
+
+func tempGray16(pix []uint8, rect image.Rectangle, _ image.Image) imageWithSubImage {
+	return &image.Gray16{Pix: pix, Stride: 2 * rect.Dx(), Rect: rect}
+}
+
+func tempYCC(pix []uint8, rect image.Rectangle, in image.Image) imageWithSubImage {
+	return &ycc{Pix: pix, Stride: 3 * rect.Dx(), Rect: rect, SubsampleRatio: in.(*ycc).SubsampleRatio}
+}
+
+func resultRGBA(rect image.Rectangle, _, dst image.Image) imageWithSubImage {
+	if m, ok := dst.(*image.RGBA); ok {
+		return m
+	}
+	return image.NewRGBA(rect)
+}
+
+func resultRGBA64(rect image.Rectangle, _, dst image.Image) imageWithSubImage {
+	if m, ok := dst.(*image.RGBA64); ok {
+		return m
+	}
+	return image.NewRGBA64(rect)
+}
+
+func resultNRGBA(rect image.Rectangle, _, dst image.Image) imageWithSubImage {
+	if m, ok := dst.(*image.NRGBA); ok {
+		return m
+	}
+	return image.NewNRGBA(rect)
+}
+
+func resultNRGBA64(rect image.Rectangle, _, dst image.Image) imageWithSubImage {
+	if m, ok := dst.(*image.NRGBA64); ok {
+		return m
+	}
+	return image.NewNRGBA64(rect)
+}
+
+func resultGray(rect image.Rectangle, _, dst image.Image) imageWithSubImage {
+	if m, ok := dst.(*image.Gray); ok {
+		return m
+	}
+	return image.NewGray(rect)
+}
+
+func resultGray16(rect image.Rectangle, _, dst image.Image) imageWithSubImage {
+	if m, ok := dst.(*image.Gray16); ok {
+		return m
+	}
+	return image.NewGray16(rect)
+}
+
+func resultYCC(rect image.Rectangle, in, _ image.Image) imageWithSubImage {
+	return newYCC(rect, in.(*ycc).SubsampleRatio)
+}
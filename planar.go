@@ -0,0 +1,187 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"context"
+	"image"
+	"image/color"
+)
+
+// resizePlanar resizes the planes of an *image.YCbCr or *image.NYCbCrA
+// independently with the gray pipeline p, the chroma planes at their own
+// subsampled resolution, like resize. The color planes of a filtered
+// *image.NYCbCrA are weighted by its alpha.
+func (r *Resizer) resizePlanar(ctx context.Context, p *pipeline, width, height uint, scaleX, scaleY, x0, y0 float64, img image.Image) (image.Image, error) {
+	var src *image.YCbCr
+	var result image.Image
+	var out *image.YCbCr
+	rect := image.Rect(0, 0, int(width), int(height))
+	switch m := img.(type) {
+	case *image.YCbCr:
+		src = m
+		out = image.NewYCbCr(rect, m.SubsampleRatio)
+		result = out
+	case *image.NYCbCrA:
+		src = &m.YCbCr
+		a := image.NewNYCbCrA(rect, m.SubsampleRatio)
+		out = &a.YCbCr
+		result = a
+	}
+	b := src.Bounds()
+
+	// The chroma samples are aligned to the luma samples they cover,
+	// the scale is the same as for luma.
+	sx, sy := subsampling(src.SubsampleRatio)
+	cin := chromaRect(b, src.SubsampleRatio)
+	cout := chromaRect(rect, src.SubsampleRatio)
+	cx0 := (float64(b.Min.X)+x0+0.5)/float64(sx) - 0.5 - float64(cin.Min.X)
+	cy0 := (float64(b.Min.Y)+y0+0.5)/float64(sy) - 0.5 - float64(cin.Min.Y)
+
+	planes := []planeResize{
+		{plane(src.Y, src.YStride, b), plane(out.Y, out.YStride, rect), x0, y0},
+		{plane(src.Cb, src.CStride, cin), plane(out.Cb, out.CStride, cout), cx0, cy0},
+		{plane(src.Cr, src.CStride, cin), plane(out.Cr, out.CStride, cout), cx0, cy0},
+	}
+	m, alpha := img.(*image.NYCbCrA)
+	premultiply := alpha && p != &nearestGrayPipeline
+	if alpha {
+		a := result.(*image.NYCbCrA)
+		planes = append(planes, planeResize{plane(m.A, m.AStride, b), plane(a.A, a.AStride, rect), x0, y0})
+		if premultiply {
+			// The color planes are weighted by alpha below.
+			planes = planes[3:]
+		}
+	}
+	for i, pl := range planes {
+		// Only the alpha plane, which comes last, can be transparent.
+		edge := r.opts.Edge
+		if edge == EdgeTransparent && !(alpha && i == len(planes)-1) {
+			edge = EdgeClamp
+		}
+		b := pl.out.Bounds()
+		if _, err := r.resizeWith(ctx, p, edge, uint(b.Dx()), uint(b.Dy()), scaleX, scaleY, pl.x0, pl.y0, pl.in, pl.out); err != nil {
+			return nil, err
+		}
+	}
+	if !premultiply {
+		return result, nil
+	}
+
+	if err := r.resizePremultiplied(ctx, scaleX, scaleY, plane(src.Y, src.YStride, b), plane(m.A, m.AStride, b), plane(out.Y, out.YStride, rect), x0, y0, 0); err != nil {
+		return nil, err
+	}
+	// The alpha of a chroma sample is the mean of the luma samples it
+	// covers.
+	ca := image.NewGray(image.Rect(0, 0, cin.Dx(), cin.Dy()))
+	for cy := cin.Min.Y; cy < cin.Max.Y; cy++ {
+		for cx := cin.Min.X; cx < cin.Max.X; cx++ {
+			covered := image.Rect(cx*sx, cy*sy, cx*sx+sx, cy*sy+sy).Intersect(b)
+			var sum int
+			for y := covered.Min.Y; y < covered.Max.Y; y++ {
+				for x := covered.Min.X; x < covered.Max.X; x++ {
+					sum += int(m.A[m.AOffset(x, y)])
+				}
+			}
+			n := covered.Dx() * covered.Dy()
+			ca.Pix[(cy-cin.Min.Y)*ca.Stride+cx-cin.Min.X] = uint8((sum + n/2) / n)
+		}
+	}
+	for _, c := range [][2][]uint8{{src.Cb, out.Cb}, {src.Cr, out.Cr}} {
+		if err := r.resizePremultiplied(ctx, scaleX, scaleY, plane(c[0], src.CStride, cin), ca, plane(c[1], out.CStride, cout), cx0, cy0, 128); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// resizePremultiplied resizes the plane in with the alpha a of its samples
+// into out, weighting each sample by its alpha like the NRGBA pipelines so
+// that the color of transparent pixels doesn't bleed into the result. The
+// premultiplied samples and the alpha are filtered with 16-bit precision,
+// fully transparent results are set to transparent.
+func (r *Resizer) resizePremultiplied(ctx context.Context, scaleX, scaleY float64, in, a, out *image.Gray, x0, y0 float64, transparent uint8) error {
+	b := in.Bounds()
+	pre := image.NewGray16(b)
+	weight := image.NewGray16(b)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			// Both are at most half of the 16-bit range, which leaves
+			// room for the overshoot of the filters.
+			alpha := uint16(a.Pix[y*a.Stride+x])
+			pre.SetGray16(x, y, color.Gray16{uint16(in.Pix[y*in.Stride+x]) * alpha >> 1})
+			weight.SetGray16(x, y, color.Gray16{alpha << 7})
+		}
+	}
+	ob := out.Bounds()
+	var filtered [2]*image.Gray16
+	for i, m := range []*image.Gray16{pre, weight} {
+		f, err := r.resizeWith(ctx, &gray16Pipeline, r.opts.Edge, uint(ob.Dx()), uint(ob.Dy()), scaleX, scaleY, x0, y0, m, nil)
+		if err != nil {
+			return err
+		}
+		filtered[i] = f.(*image.Gray16)
+	}
+	for y := 0; y < ob.Dy(); y++ {
+		for x := 0; x < ob.Dx(); x++ {
+			v := transparent
+			if w := int32(filtered[1].Gray16At(x, y).Y); w > 0 {
+				v = clampUint8((int32(filtered[0].Gray16At(x, y).Y)<<8 + w/2) / w)
+			}
+			out.Pix[y*out.Stride+x] = v
+		}
+	}
+	return nil
+}
+
+// A planeResize resizes the plane in to the size of out, sampling at the
+// origin x0, y0.
+type planeResize struct {
+	in, out *image.Gray
+	x0, y0  float64
+}
+
+// plane returns the samples of a plane with the size of r as *image.Gray
+// at the origin.
+func plane(pix []uint8, stride int, r image.Rectangle) *image.Gray {
+	return &image.Gray{Pix: pix, Stride: stride, Rect: image.Rect(0, 0, r.Dx(), r.Dy())}
+}
+
+// subsampling returns the horizontal and vertical chroma subsampling
+// factors of s.
+func subsampling(s image.YCbCrSubsampleRatio) (int, int) {
+	switch s {
+	case image.YCbCrSubsampleRatio422:
+		return 2, 1
+	case image.YCbCrSubsampleRatio420:
+		return 2, 2
+	case image.YCbCrSubsampleRatio440:
+		return 1, 2
+	case image.YCbCrSubsampleRatio411:
+		return 4, 1
+	case image.YCbCrSubsampleRatio410:
+		return 4, 2
+	}
+	return 1, 1
+}
+
+// chromaRect returns the chroma samples of the luma rectangle r, rounded
+// like the image package does.
+func chromaRect(r image.Rectangle, s image.YCbCrSubsampleRatio) image.Rectangle {
+	sx, sy := subsampling(s)
+	return image.Rect(r.Min.X/sx, r.Min.Y/sy, (r.Max.X+sx-1)/sx, (r.Max.Y+sy-1)/sy)
+}
@@ -0,0 +1,152 @@
+package resize
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var subsampleRatios = []image.YCbCrSubsampleRatio{
+	image.YCbCrSubsampleRatio444,
+	image.YCbCrSubsampleRatio422,
+	image.YCbCrSubsampleRatio420,
+	image.YCbCrSubsampleRatio440,
+	image.YCbCrSubsampleRatio411,
+	image.YCbCrSubsampleRatio410,
+}
+
+func Test_PlanarSameColor(t *testing.T) {
+	for _, s := range subsampleRatios {
+		img := image.NewYCbCr(image.Rect(1, 3, 52, 44), s)
+		for i := range img.Y {
+			img.Y[i] = 100
+		}
+		for i := range img.Cb {
+			img.Cb[i] = 50
+			img.Cr[i] = 200
+		}
+		m, err := Resize(17, 13, img, Lanczos3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, ok := m.(*image.YCbCr)
+		if !ok || out.SubsampleRatio != s {
+			t.Fatalf("%v: got %T, want *image.YCbCr with the same subsampling", s, m)
+		}
+		b := out.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if c := out.YCbCrAt(x, y); c.Y != 100 || c.Cb != 50 || c.Cr != 200 {
+					t.Fatalf("%v: got %v at %d,%d", s, c, x, y)
+				}
+			}
+		}
+	}
+}
+
+func Test_PlanarLuma(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 64, 48), image.YCbCrSubsampleRatio420)
+	gray := image.NewGray(img.Rect)
+	for i := range img.Y {
+		img.Y[i] = uint8(i * 7)
+		gray.Pix[i] = img.Y[i]
+	}
+	m, err := Resize(30, 20, img, Bicubic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Resize(30, 20, gray, Bicubic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.(*image.YCbCr).Y, want.(*image.Gray).Pix) {
+		t.Error("luma plane differs from the resized gray image")
+	}
+}
+
+func Test_PlanarChromaFiltered(t *testing.T) {
+	// Alternating chroma columns are averaged instead of point-sampled.
+	img := image.NewYCbCr(image.Rect(0, 0, 64, 64), image.YCbCrSubsampleRatio420)
+	for i := range img.Cb {
+		if i%2 == 0 {
+			img.Cb[i] = 60
+		} else {
+			img.Cb[i] = 180
+		}
+	}
+	m, err := Resize(32, 32, img, Bilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := m.(*image.YCbCr)
+	for _, v := range out.Cb[out.CStride : 2*out.CStride] {
+		if v < 90 || v > 150 {
+			t.Fatalf("got chroma %d, want about 120", v)
+		}
+	}
+}
+
+func Test_NYCbCrA(t *testing.T) {
+	img := image.NewNYCbCrA(image.Rect(0, 0, 64, 48), image.YCbCrSubsampleRatio420)
+	alpha := image.NewGray(img.Rect)
+	for i := range img.Y {
+		img.Y[i] = 100
+		img.A[i] = uint8(i * 5)
+		alpha.Pix[i] = img.A[i]
+	}
+	for i := range img.Cb {
+		img.Cb[i] = 50
+		img.Cr[i] = 200
+	}
+	for _, interp := range []InterpolationFunction{NearestNeighbor, Lanczos3} {
+		m, err := Resize(30, 20, img, interp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, ok := m.(*image.NYCbCrA)
+		if !ok || out.SubsampleRatio != img.SubsampleRatio {
+			t.Fatalf("got %T, want *image.NYCbCrA with the same subsampling", m)
+		}
+		if c := out.NYCbCrAAt(17, 11); c.Y != 100 || c.Cb != 50 || c.Cr != 200 {
+			t.Errorf("got %v, want the color of the source", c)
+		}
+		want, err := Resize(30, 20, alpha, interp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.A, want.(*image.Gray).Pix) {
+			t.Error("alpha plane differs from the resized gray image")
+		}
+	}
+}
+
+func Test_NYCbCrATransparentColor(t *testing.T) {
+	// A transparent white half next to an opaque black one, the color of
+	// the transparent pixels must not bleed into the result.
+	img := image.NewNYCbCrA(image.Rect(0, 0, 32, 16), image.YCbCrSubsampleRatio420)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Y[img.YOffset(x, y)] = 0xff
+			img.A[img.AOffset(x+16, y)] = 0xff
+		}
+	}
+	for i := range img.Cb {
+		img.Cb[i], img.Cr[i] = 128, 128
+	}
+	for _, interp := range []InterpolationFunction{Bilinear, Lanczos3} {
+		m, err := Resize(13, 7, img, interp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := m.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+				if c.A > 0 && (c.R > 2 || c.G > 2 || c.B > 2) {
+					t.Fatalf("got %v at %d,%d, want black", c, x, y)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import "sync"
+
+// A WorkerPool is a fixed number of goroutines that can be shared by
+// concurrent resize operations, see Options.Pool. It bounds the number of
+// goroutines used for resizing in a process, no matter how many images are
+// resized at the same time.
+type WorkerPool struct {
+	work chan func()
+	once sync.Once
+}
+
+// NewWorkerPool starts a WorkerPool with n goroutines.
+func NewWorkerPool(n int) *WorkerPool {
+	p := &WorkerPool{work: make(chan func())}
+	for i := 0; i < n; i++ {
+		go func() {
+			for fn := range p.work {
+				fn()
+			}
+		}()
+	}
+	return p
+}
+
+// Close stops the goroutines of the pool. The pool must not be used
+// afterwards.
+func (p *WorkerPool) Close() {
+	p.once.Do(func() {
+		close(p.work)
+	})
+}
+
+// submit runs fn on an idle goroutine of the pool. It returns false
+// without running fn if there is none.
+func (p *WorkerPool) submit(fn func()) bool {
+	select {
+	case p.work <- fn:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,45 @@
+package resize
+
+import (
+	"bytes"
+	"image"
+	"sync"
+	"testing"
+)
+
+func Test_Workers(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 3)
+	}
+	want, err := Resize(130, 70, img, Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := NewWorkerPool(3)
+	defer pool.Close()
+	for _, opts := range []*Options{
+		{Workers: 1},
+		{Workers: 7},
+		{Pool: pool},
+		{Workers: 2, Pool: pool},
+	} {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(opts *Options) {
+				defer wg.Done()
+				got, err := ResizeWithOptions(130, 70, img, Lanczos3, opts)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(want.(*image.RGBA).Pix, got.(*image.RGBA).Pix) {
+					t.Errorf("%+v: output differs", opts)
+				}
+			}(opts)
+		}
+		wg.Wait()
+	}
+}
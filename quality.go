@@ -0,0 +1,90 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"context"
+	"image"
+	"math"
+)
+
+// A Quality trades accuracy for speed on large reductions. Below
+// QualityBest, the image is first reduced by an integer factor with an
+// Area filter, which is cheap, and then to the target size with the
+// interpolation function, whose number of taps grows with the reduction.
+type Quality int
+
+const (
+	// QualityBest applies the interpolation function to the whole
+	// reduction.
+	QualityBest Quality = iota
+	// QualityHigh prefilters reductions of 4x and more, leaving 2x to 3x
+	// to the interpolation function.
+	QualityHigh
+	// QualityFast prefilters reductions of 2x and more, leaving 1x to
+	// 1.5x to the interpolation function.
+	QualityFast
+)
+
+// reduction returns the smallest reduction left to the interpolation
+// function, or 0 if the image isn't prefiltered.
+func (q Quality) reduction() float64 {
+	switch q {
+	case QualityHigh:
+		return 2
+	case QualityFast:
+		return 1
+	}
+	return 0
+}
+
+// prefilterFactor returns the integer factor an axis reduced by scale is
+// prefiltered with, 1 if it isn't.
+func (r *Resizer) prefilterFactor(scale float64) int {
+	m := r.opts.Quality.reduction()
+	if m == 0 || r.interp == nil || r.interp == NearestNeighbor || r.interp == Area {
+		return 1
+	}
+	return int(math.Max(math.Floor(scale/m), 1))
+}
+
+// prefilter reduces img by the factors kx and ky with an Area filter and
+// returns the result with the scales and origins of resize mapped onto it.
+func (r *Resizer) prefilter(ctx context.Context, kx, ky int, scaleX, scaleY, x0, y0 float64, img image.Image) (image.Image, float64, float64, float64, float64, error) {
+	b := img.Bounds()
+	width, height := prefilterSize(b, kx, ky)
+	sx := float64(b.Dx()) / float64(width)
+	sy := float64(b.Dy()) / float64(height)
+
+	box := &Resizer{interp: Area, opts: r.opts, buffers: r.buffers}
+	box.opts.Quality, box.opts.Sharpen = QualityBest, nil
+	m, err := box.resize(ctx, width, height, sx, sy, 0, 0, img, nil)
+	if err != nil {
+		return nil, 0, 0, 0, 0, err
+	}
+	// Pixel centers of the source map to those of the prefiltered image.
+	x0 = (x0+0.5)/sx - 0.5
+	y0 = (y0+0.5)/sy - 0.5
+	return m, scaleX / sx, scaleY / sy, x0, y0, nil
+}
+
+// prefilterSize returns the size prefilter reduces the bounds b to.
+func prefilterSize(b image.Rectangle, kx, ky int) (uint, uint) {
+	width := uint(math.Max(math.Round(float64(b.Dx())/float64(kx)), 1))
+	height := uint(math.Max(math.Round(float64(b.Dy())/float64(ky)), 1))
+	return width, height
+}
@@ -0,0 +1,78 @@
+package resize
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func Test_PrefilterFactor(t *testing.T) {
+	for _, c := range []struct {
+		quality Quality
+		scale   float64
+		want    int
+	}{
+		{QualityBest, 20, 1},
+		{QualityHigh, 3.9, 1},
+		{QualityHigh, 4, 2},
+		{QualityHigh, 20, 10},
+		{QualityHigh, 21.5, 10},
+		{QualityFast, 1.9, 1},
+		{QualityFast, 2.9, 2},
+		{QualityFast, 20, 20},
+	} {
+		r := newResizer(Lanczos3, &Options{Quality: c.quality})
+		if got := r.prefilterFactor(c.scale); got != c.want {
+			t.Errorf("quality %d, scale %v: got %d, want %d", c.quality, c.scale, got, c.want)
+		}
+	}
+	if got := newResizer(Area, &Options{Quality: QualityFast}).prefilterFactor(20); got != 1 {
+		t.Errorf("got %d for Area, want 1", got)
+	}
+}
+
+func Test_Quality(t *testing.T) {
+	// Smooth gradients come out about the same with a prefilter.
+	img := image.NewRGBA(image.Rect(0, 0, 600, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 600; x++ {
+			i := img.PixOffset(x, y)
+			img.Pix[i+0] = uint8(x * 255 / 600)
+			img.Pix[i+1] = uint8(y * 255 / 400)
+			img.Pix[i+3] = 0xff
+		}
+	}
+	want, err := Resize(30, 20, img, Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []Quality{QualityHigh, QualityFast} {
+		m, err := ResizeWithOptions(30, 20, img, Lanczos3, &Options{Quality: q})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := m.(*image.RGBA)
+		if got.Bounds() != want.Bounds() {
+			t.Fatalf("got bounds %v, want %v", got.Bounds(), want.Bounds())
+		}
+		for i, v := range got.Pix {
+			if d := int(v) - int(want.(*image.RGBA).Pix[i]); d < -3 || d > 3 {
+				t.Fatalf("quality %d: got %d at %d, want about %d", q, v, i, want.(*image.RGBA).Pix[i])
+			}
+		}
+	}
+}
+
+func Test_QualityLimits(t *testing.T) {
+	// The prefiltered image is 200 pixels wide, its temporary image is
+	// larger than that of resizing to 100 pixels directly.
+	img := image.NewRGBA(image.Rect(0, 0, 2000, 2000))
+	opts := &Options{Limits: Limits{MaxMemory: 1 << 20}}
+	if _, err := ResizeWithOptions(100, 100, img, Lanczos3, opts); err != nil {
+		t.Fatal(err)
+	}
+	opts.Quality = QualityHigh
+	if _, err := ResizeWithOptions(100, 100, img, Lanczos3, opts); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("got error %v, want %v", err, ErrLimitExceeded)
+	}
+}
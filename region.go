@@ -0,0 +1,54 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"image"
+	"math"
+)
+
+// A Region is an area of an image in the coordinate space of its bounds.
+// Unlike image.Rectangle its coordinates may be fractional, to select
+// parts of pixels. Pixel x covers the interval [x, x+1).
+type Region struct {
+	X0, Y0, X1, Y1 float64
+}
+
+// RectRegion returns the Region covering the pixels of rect.
+func RectRegion(rect image.Rectangle) Region {
+	return Region{float64(rect.Min.X), float64(rect.Min.Y), float64(rect.Max.X), float64(rect.Max.Y)}
+}
+
+// Dx returns g's width.
+func (g Region) Dx() float64 {
+	return g.X1 - g.X0
+}
+
+// Dy returns g's height.
+func (g Region) Dy() float64 {
+	return g.Y1 - g.Y0
+}
+
+// valid reports whether g is finite and not empty.
+func (g Region) valid() bool {
+	for _, v := range [...]float64{g.X0, g.Y0, g.X1, g.Y1} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return g.X0 < g.X1 && g.Y0 < g.Y1
+}
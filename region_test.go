@@ -0,0 +1,88 @@
+package resize
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"testing"
+)
+
+func Test_RegionMatchesResize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 20, 74, 68))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 13)
+	}
+	want, err := Resize(20, 15, img, Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ResizeRegion(20, 15, img, RectRegion(img.Bounds()), Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want.(*image.RGBA).Pix, got.(*image.RGBA).Pix) {
+		t.Error("region covering the image differs from Resize")
+	}
+}
+
+func Test_RegionCrop(t *testing.T) {
+	// Black left half, gray right half.
+	img := image.NewGray(image.Rect(0, 0, 100, 40))
+	for y := 0; y < 40; y++ {
+		for x := 50; x < 100; x++ {
+			img.Pix[y*img.Stride+x] = 0x80
+		}
+	}
+
+	m, err := ResizeRegion(20, 0, img, Region{60, 0, 100, 40}, Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != image.Rect(0, 0, 20, 20) {
+		t.Fatalf("got bounds %v, want %v", m.Bounds(), image.Rect(0, 0, 20, 20))
+	}
+	for _, v := range m.(*image.Gray).Pix {
+		if v != 0x80 {
+			t.Fatalf("got %d, want only gray pixels", v)
+		}
+	}
+
+	// The filter reads the black pixels left of the region.
+	m, err = ResizeRegion(20, 0, img, Region{50, 0, 90, 40}, Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := m.(*image.Gray).Pix[0]; v == 0x80 {
+		t.Error("pixels around the region were not filtered")
+	}
+}
+
+func Test_RegionSubPixel(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 40; x++ {
+			img.Pix[y*img.Stride+x] = uint8(x * 5)
+		}
+	}
+
+	var row [3]uint8
+	for i, x0 := range []float64{10, 10.25, 10.5} {
+		m, err := ResizeRegion(10, 4, img, Region{x0, 0, x0 + 10, 4}, Bilinear)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row[i] = m.(*image.Gray).Pix[0]
+	}
+	if !(row[0] < row[1] && row[1] < row[2]) {
+		t.Errorf("got %v, want strictly increasing values", row)
+	}
+}
+
+func Test_InvalidRegion(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 10, 10))
+	for _, g := range []Region{{}, {5, 0, 2, 10}, {0, 0, 10, math.Inf(1)}} {
+		if _, err := ResizeRegion(5, 5, img, g, Bilinear); err != ErrInvalidRegion {
+			t.Errorf("%v: got error %v, want %v", g, err, ErrInvalidRegion)
+		}
+	}
+}
@@ -25,557 +25,445 @@ THIS SOFTWARE.
 package resize
 
 import (
+	"context"
 	"image"
+	"image/color"
+	"image/draw"
+	"math"
 	"runtime"
-	"strings"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 )
 
+// A Filter describes an interpolation kernel. Support is the radius of the
+// kernel in source pixels at a scale of 1 and Kernel is the function used
+// for sampling; it should be zero outside of [-Support, Support].
+// Custom kernels can be used wherever an InterpolationFunction is expected.
+type Filter struct {
+	Support float64
+	Kernel  func(float64) float64
+}
+
 // An InterpolationFunction provides the parameters that describe an
-// interpolation kernel. It returns the number of samples to take
-// and the kernel function to use for sampling.
-type InterpolationFunction int
+// interpolation kernel. It is a pointer to the Filter to use for sampling.
+type InterpolationFunction = *Filter
 
-// InterpolationFunction constants
-const (
+// Predefined InterpolationFunctions
+var (
 	// Nearest-neighbor interpolation
-	NearestNeighbor InterpolationFunction = iota
+	NearestNeighbor InterpolationFunction = &Filter{0.5, nearest}
 	// Bilinear interpolation
-	Bilinear
+	Bilinear InterpolationFunction = &Filter{1, linear}
 	// Bicubic interpolation (with cubic hermite spline)
-	Bicubic
+	Bicubic InterpolationFunction = &Filter{2, cubic}
 	// Mitchell-Netravali interpolation
-	MitchellNetravali
+	MitchellNetravali InterpolationFunction = &Filter{2, mitchellnetravali}
 	// Lanczos interpolation (a=2)
-	Lanczos2
+	Lanczos2 InterpolationFunction = &Filter{2, lanczos2}
 	// Lanczos interpolation (a=3)
-	Lanczos3
+	Lanczos3 InterpolationFunction = &Filter{3, lanczos3}
+	// Gaussian interpolation (sigma=0.5)
+	Gaussian InterpolationFunction = &Filter{1.5, gaussian}
+	// Cubic hermite interpolation, smooth but without overshoot
+	Hermite InterpolationFunction = &Filter{1, hermite}
+	// Cubic B-spline interpolation, blurry but without ringing
+	BSpline InterpolationFunction = &Filter{2, bspline}
+	// Catmull-Rom interpolation, the same as Bicubic
+	CatmullRom InterpolationFunction = Bicubic
+	// Cubic interpolation with the parameters suggested by Nicolas Robidoux
+	// for resampling with a cylindrical filter, a sharper MitchellNetravali
+	Robidoux InterpolationFunction = &Filter{2, bcCubic(robidouxB, robidouxC)}
+	// Sinc interpolation with a Hann window (a=3)
+	Hann InterpolationFunction = &Filter{3, windowedSinc(3, hann)}
+	// Sinc interpolation with a Hamming window (a=3)
+	Hamming InterpolationFunction = &Filter{3, windowedSinc(3, hamming)}
+	// Sinc interpolation with a Blackman window (a=3)
+	Blackman InterpolationFunction = &Filter{3, windowedSinc(3, blackman)}
+	// Sinc interpolation with a Kaiser window (a=3, beta=6.5)
+	Kaiser InterpolationFunction = &Filter{3, windowedSinc(3, kaiser)}
+	// Area averaging, the mean of the covered source pixels weighted by
+	// their exact coverage. Alias-free and fast for large reductions,
+	// Options.Blur has no effect.
+	Area InterpolationFunction = &Filter{0.5, nearest}
 )
 
-// kernal, returns an InterpolationFunctions taps and kernel.
-func (i InterpolationFunction) kernel() (int, func(float64) float64) {
-	switch i {
-	case Bilinear:
-		return 2, linear
-	case Bicubic:
-		return 4, cubic
-	case MitchellNetravali:
-		return 4, mitchellnetravali
-	case Lanczos2:
-		return 4, lanczos2
-	case Lanczos3:
-		return 6, lanczos3
-	default:
-		// Default to NearestNeighbor.
-		return 2, nearest
+// Parameters of the Robidoux filter.
+const (
+	robidouxB = 0.37821575509399867
+	robidouxC = 0.31089212245300067
+)
+
+// BCCubic returns a cubic interpolation of the Mitchell-Netravali family
+// with the parameters b and c. Notable members are BSpline (1, 0),
+// CatmullRom (0, 0.5), MitchellNetravali (1/3, 1/3) and Robidoux, for
+// which the predefined InterpolationFunctions are returned.
+func BCCubic(b, c float64) InterpolationFunction {
+	switch {
+	case b == 1 && c == 0:
+		return BSpline
+	case b == 0 && c == 0.5:
+		return CatmullRom
+	case b == 1.0/3 && c == 1.0/3:
+		return MitchellNetravali
+	case b == robidouxB && c == robidouxC:
+		return Robidoux
 	}
+	return &Filter{2, bcCubic(b, c)}
 }
 
-// values <1 will sharpen the image
-var blur = 1.0
-
-// Resize scales an image to new width and height using the interpolation function interp.
-// A new image with the given dimensions will be returned.
-// If one of the parameters width or height is set to 0, its size will be calculated so that
-// the aspect ratio is that of the originating image.
-// The resizing algorithm uses channels for parallel computation.
-func Resize(width, height uint, img image.Image, interp InterpolationFunction) (image.Image, error) {
-	scaleX, scaleY := calcFactors(width, height, float64(img.Bounds().Dx()), float64(img.Bounds().Dy()))
-	if width == 0 {
-		width = uint(0.7 + float64(img.Bounds().Dx())/scaleX)
-	}
-	if height == 0 {
-		height = uint(0.7 + float64(img.Bounds().Dy())/scaleY)
+// MaxLanczosLobes is the largest number of lobes of Lanczos.
+const MaxLanczosLobes = 16
+
+// Lanczos returns a Lanczos interpolation with the given number of lobes,
+// clamped to the range [1,MaxLanczosLobes].
+func Lanczos(lobes int) InterpolationFunction {
+	switch {
+	case lobes < 1:
+		lobes = 1
+	case lobes > MaxLanczosLobes:
+		lobes = MaxLanczosLobes
+	case lobes == 2:
+		return Lanczos2
+	case lobes == 3:
+		return Lanczos3
 	}
+	return &Filter{float64(lobes), lanczos(float64(lobes))}
+}
 
-	// Trivial case: return input image
-	if int(width) == img.Bounds().Dx() && int(height) == img.Bounds().Dy() {
-		return img, nil
+// kernel, returns a Filter's taps and kernel.
+func (f *Filter) kernel() (int, func(float64) float64) {
+	if f == nil || f.Kernel == nil {
+		// Default to NearestNeighbor.
+		return NearestNeighbor.kernel()
 	}
-
-	if interp == NearestNeighbor {
-		return resizeNearest(width, height, scaleX, scaleY, img, interp)
+	taps := 2 * int(math.Ceil(f.Support))
+	if taps < 2 {
+		taps = 2
 	}
+	return taps, f.Kernel
+}
 
-	taps, kernel := interp.kernel()
-	cpus := runtime.NumCPU()
-	wg := sync.WaitGroup{}
-	var panics chan string
-
-	// Generic access to image.Image is slow in tight loops.
-	// The optimal access has to be determined from the concrete image type.
-	switch input := img.(type) {
-	case *image.RGBA:
-		// 8-bit precision
-		temp := image.NewRGBA(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights8(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeRGBA(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights8(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeRGBA(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
-
-		return result, nil
-	case *image.YCbCr:
-		// 8-bit precision
-		// accessing the YCbCr arrays in a tight loop is slow.
-		// converting the image to ycc increases performance by 2x.
-		temp := newYCC(image.Rect(0, 0, input.Bounds().Dy(), int(width)), input.SubsampleRatio)
-		result := newYCC(image.Rect(0, 0, int(width), int(height)), input.SubsampleRatio)
-
-		coeffs, offset, filterLength := createWeights8(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		in := imageYCbCrToYCC(input)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*ycc)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeYCbCr(in, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
-
-		coeffs, offset, filterLength = createWeights8(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*ycc)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeYCbCr(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
-
-		return result.YCbCr(), nil
-	case *image.RGBA64:
-		// 16-bit precision
-		temp := image.NewRGBA64(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewRGBA64(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights16(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA64)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeRGBA64(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights16(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA64)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeGeneric(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
+// Range of Options.Blur.
+const (
+	MinBlur = 0.1
+	MaxBlur = 10
+)
 
-		return result, nil
-	case *image.Gray:
-		// 8-bit precision
-		temp := image.NewGray(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewGray(image.Rect(0, 0, int(width), int(height)))
+// Options configure optional behaviour of ResizeWithOptions.
+// The zero value gives the same results as Resize.
+type Options struct {
+	// Linear enables gamma-correct resampling. The sRGB encoded samples are
+	// converted to linear light before filtering and re-encoded afterwards.
+	// *image.YCbCr images are returned as *image.RGBA in this mode.
+	Linear bool
+	// Workers is the number of goroutines used for each pass. If it is 0,
+	// up to runtime.NumCPU() goroutines are used depending on the image size,
+	// small images are resized by the calling goroutine only.
+	Workers int
+	// Pool runs the work on a shared pool of goroutines instead of starting
+	// new ones for each pass. If all of its goroutines are busy, fewer
+	// workers are used.
+	Pool *WorkerPool
+	// Limits bound the size of the images and the memory a resize
+	// operation may use.
+	Limits Limits
+	// Anchor positions the image for the Fill and Pad modes of ResizeMode.
+	Anchor Anchor
+	// Background is the color of the padding added by the Pad mode.
+	// If it is nil, the padding is left zero: transparent, or black
+	// for gray images.
+	Background color.Color
+	// Palette selects the conversion of *image.Paletted images.
+	Palette PaletteMode
+	// Dither enables Floyd-Steinberg dithering when the result is
+	// re-quantized to a palette.
+	Dither bool
+	// Edge selects the samples used outside of the source image,
+	// EdgeClamp by default.
+	Edge Edge
+	// TileMemory bounds the bytes of the temporary image between the
+	// two passes. Larger images are resized in bands of output rows,
+	// with the same result. A band has at least one row, and with
+	// EdgeWrap the bands at the edges read all rows, which Limits takes
+	// into account. 0 means no bound.
+	TileMemory int64
+	// Quality selects how much of a large reduction is done with a fast
+	// prefilter, QualityBest by default.
+	Quality Quality
+	// Blur scales the support of the filter. Values > 1 blur the image,
+	// values < 1 sharpen it. It must be in the range MinBlur to MaxBlur,
+	// 0 means 1.
+	Blur float64
+	// Sharpen applies an unsharp mask to the result, e.g. to make up for
+	// the softness of large reductions, also if the result has the size
+	// of the source. nil disables it. ResizeGIF returns animations that
+	// keep their size unchanged, and ResizeStream ignores it.
+	Sharpen *Sharpen
+}
 
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights8(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.Gray)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeGray(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
+// validate checks the options that can be out of range.
+func (o *Options) validate() error {
+	if o.Blur != 0 && !(o.Blur >= MinBlur && o.Blur <= MaxBlur) {
+		return ErrInvalidBlur
+	}
+	if o.Sharpen != nil && !o.Sharpen.valid() {
+		return ErrInvalidSharpen
+	}
+	return nil
+}
 
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights8(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.Gray)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeGray(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
+// blur returns the blur factor passed to the weight functions.
+func (o *Options) blur() float64 {
+	if o.Blur == 0 {
+		return 1
+	}
+	return o.Blur
+}
 
-		return result, nil
-	case *image.Gray16:
-		// 16-bit precision
-		temp := image.NewGray16(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewGray16(image.Rect(0, 0, int(width), int(height)))
+// Resize scales an image to new width and height using the interpolation function interp.
+// A new image with the given dimensions will be returned.
+// If one of the parameters width or height is set to 0, its size will be calculated so that
+// the aspect ratio is that of the originating image.
+// The resizing algorithm uses channels for parallel computation.
+func Resize(width, height uint, img image.Image, interp InterpolationFunction) (image.Image, error) {
+	return ResizeWithOptions(width, height, img, interp, nil)
+}
 
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights16(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.Gray16)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeGray16(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
+// ResizeWithOptions works like Resize, with the additional behaviour
+// selected by opts. A nil opts is the same as calling Resize.
+func ResizeWithOptions(width, height uint, img image.Image, interp InterpolationFunction, opts *Options) (image.Image, error) {
+	return newResizer(interp, opts).Resize(width, height, img)
+}
 
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights16(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.Gray16)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeGray16(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
+// ResizeContext works like ResizeWithOptions. The resizing is aborted
+// when ctx is done, in which case ctx.Err() is returned.
+func ResizeContext(ctx context.Context, width, height uint, img image.Image, interp InterpolationFunction, opts *Options) (image.Image, error) {
+	return newResizer(interp, opts).ResizeContext(ctx, width, height, img)
+}
 
-		return result, nil
-	default:
-		// 16-bit precision
-		temp := image.NewRGBA64(image.Rect(0, 0, img.Bounds().Dy(), int(width)))
-		result := image.NewRGBA64(image.Rect(0, 0, int(width), int(height)))
+// ResizeInto scales src to the size of dst.Bounds() using the interpolation
+// function interp and writes the result into dst. dst may be a sub-image of
+// a larger canvas. If dst has the same type Resize would return for src,
+// the result is written directly without an intermediate copy.
+func ResizeInto(dst draw.Image, src image.Image, interp InterpolationFunction) error {
+	return newResizer(interp, nil).ResizeInto(dst, src)
+}
 
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeights16(temp.Bounds().Dy(), taps, blur, scaleX, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA64)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeGeneric(img, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
+// ResizeRegion scales the area region of img to width and height using the
+// interpolation function interp, cropping and resizing in one pass. The
+// filter reads the pixels around region, so unlike resizing a SubImage
+// there are no artifacts at the edges of the crop; outside of img the edge
+// pixels are repeated. If one of width or height is 0, it is calculated
+// from the aspect ratio of region.
+func ResizeRegion(width, height uint, img image.Image, region Region, interp InterpolationFunction) (image.Image, error) {
+	return newResizer(interp, nil).ResizeRegion(width, height, img, region)
+}
 
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeights16(result.Bounds().Dy(), taps, blur, scaleY, kernel)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA64)
-			go func() {
-				defer recoverfn(&wg, panics)
-				resizeRGBA64(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
+// resize scales img to width and height. The result pixel x, y samples the
+// source at x0+scaleX*(x+0.5), y0+scaleY*(y+0.5), relative to the bounds of
+// img. The result is written to dst if dst has the type of the result and
+// is at the origin, otherwise a new image is allocated. The result is
+// sharpened if Options.Sharpen is set.
+func (r *Resizer) resize(ctx context.Context, width, height uint, scaleX, scaleY, x0, y0 float64, img, dst image.Image) (image.Image, error) {
+	if kx, ky := r.prefilterFactor(scaleX), r.prefilterFactor(scaleY); kx > 1 || ky > 1 {
+		var err error
+		img, scaleX, scaleY, x0, y0, err = r.prefilter(ctx, kx, ky, scaleX, scaleY, x0, y0, img)
+		if err != nil {
 			return nil, err
 		}
-
-		return result, nil
 	}
+	m, err := r.resizeImage(ctx, width, height, scaleX, scaleY, x0, y0, img, dst)
+	if err == nil && r.opts.Sharpen != nil {
+		err = r.sharpen(ctx, m, r.opts.Sharpen)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-func resizeNearest(width, height uint, scaleX, scaleY float64, img image.Image, interp InterpolationFunction) (image.Image, error) {
-	taps, _ := interp.kernel()
-	cpus := runtime.NumCPU()
-	wg := sync.WaitGroup{}
-	var panics chan string
-
-	switch input := img.(type) {
-	case *image.RGBA:
-		// 8-bit precision
-		temp := image.NewRGBA(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestRGBA(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestRGBA(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
-
-		return result, nil
+// resizeImage resizes img like resize, with the pipeline for its type.
+func (r *Resizer) resizeImage(ctx context.Context, width, height uint, scaleX, scaleY, x0, y0 float64, img, dst image.Image) (image.Image, error) {
+	nearest := r.interp == nil || r.interp == NearestNeighbor
+	switch img.(type) {
 	case *image.YCbCr:
-		// 8-bit precision
-		// accessing the YCbCr arrays in a tight loop is slow.
-		// converting the image to ycc increases performance by 2x.
-		temp := newYCC(image.Rect(0, 0, input.Bounds().Dy(), int(width)), input.SubsampleRatio)
-		result := newYCC(image.Rect(0, 0, int(width), int(height)), input.SubsampleRatio)
-
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		in := imageYCbCrToYCC(input)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*ycc)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestYCbCr(in, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
-
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*ycc)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestYCbCr(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
+		if !nearest {
+			return r.resizePlanar(ctx, &grayPipeline, width, height, scaleX, scaleY, x0, y0, img)
 		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
+	case *image.NYCbCrA:
+		if nearest {
+			return r.resizePlanar(ctx, &nearestGrayPipeline, width, height, scaleX, scaleY, x0, y0, img)
 		}
+		return r.resizePlanar(ctx, &grayPipeline, width, height, scaleX, scaleY, x0, y0, img)
+	}
+	p := pipelineFor(img, nearest)
+	return r.resizeWith(ctx, p, r.edge(p), width, height, scaleX, scaleY, x0, y0, img, dst)
+}
 
-		return result.YCbCr(), nil
-	case *image.RGBA64:
-		// 16-bit precision
-		temp := image.NewRGBA64(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewRGBA64(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA64)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestRGBA64(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
+// edge returns the edge mode used with the pipeline p.
+func (r *Resizer) edge(p *pipeline) Edge {
+	if r.opts.Edge == EdgeTransparent && p.opaque {
+		return EdgeClamp
+	}
+	return r.opts.Edge
+}
 
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA64)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestGeneric(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
+// resizeWith resizes img like resize, using the pipeline p and the edge
+// mode edge.
+func (r *Resizer) resizeWith(ctx context.Context, p *pipeline, edge Edge, width, height uint, scaleX, scaleY, x0, y0 float64, img, dst image.Image) (image.Image, error) {
+	if width == 0 || height == 0 {
+		in := subRows(img, 0, 0)
+		if p.prepare != nil {
+			in = p.prepare(in)
 		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
+		result := p.result(image.Rect(0, 0, int(width), int(height)), in, dst)
+		if p.finish != nil {
+			return p.finish(result), nil
 		}
-
 		return result, nil
-	case *image.Gray:
-		// 8-bit precision
-		temp := image.NewGray(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewGray(image.Rect(0, 0, int(width), int(height)))
-
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.Gray)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestGray(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
-
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.Gray)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestGray(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
+	}
+	wy := r.weightsFor(p.precision, int(height), scaleY, y0)
+	wx := r.weightsFor(p.precision, int(width), scaleX, x0)
+	bands := r.bands(wy, p.bpp*int(width))
+
+	// Only the source rows read by the vertical pass for a band of
+	// output rows are filtered by the horizontal pass.
+	rows := make([]image.Rectangle, len(bands)-1)
+	maxRows := 0
+	for i := range rows {
+		rows[i] = sourceRows(wy, bands[i], bands[i+1], img.Bounds().Dy(), edge)
+		if rows[i].Dy() > maxRows {
+			maxRows = rows[i].Dy()
 		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
+	}
+	pix := r.buffer(p.bpp * maxRows * int(width))
+	defer r.release(pix)
+
+	var result imageWithSubImage
+	var offset []int
+	for i, band := range rows {
+		w := wy
+		if band.Min.Y != 0 {
+			// The offsets of the band relative to its first source row.
+			if offset == nil {
+				offset = make([]int, len(wy.offset))
+			}
+			shifted := *wy
+			shifted.offset = offset
+			for y := bands[i]; y < bands[i+1]; y++ {
+				offset[y] = wy.offset[y] - band.Min.Y
+			}
+			w = &shifted
+		}
+		in := subRows(img, band.Min.Y, band.Max.Y)
+		if p.prepare != nil {
+			in = p.prepare(in)
+		}
+		rect := image.Rect(0, 0, band.Dy(), int(width))
+		temp := p.temp(pix[:p.bpp*rect.Dx()*rect.Dy()], rect, in)
+		if result == nil {
+			result = p.result(image.Rect(0, 0, int(width), int(height)), in, dst)
 		}
 
-		return result, nil
-	case *image.Gray16:
-		// 16-bit precision
-		temp := image.NewGray16(image.Rect(0, 0, input.Bounds().Dy(), int(width)))
-		result := image.NewGray16(image.Rect(0, 0, int(width), int(height)))
-
 		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.Gray16)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestGray16(input, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
+		if err := r.resizePass(ctx, Horizontal, temp, func(slice image.Image) {
+			p.horizontal(in, slice, wx, edge)
+		}); err != nil {
 			return nil, err
 		}
 
 		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.Gray16)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestGray16(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
+		out := result.SubImage(image.Rect(0, bands[i], int(width), bands[i+1])).(imageWithSubImage)
+		if err := r.resizePass(ctx, Vertical, out, func(slice image.Image) {
+			p.vertical(temp, slice, w, edge)
+		}); err != nil {
 			return nil, err
 		}
+	}
 
-		return result, nil
-	default:
-		// 16-bit precision
-		temp := image.NewRGBA64(image.Rect(0, 0, img.Bounds().Dy(), int(width)))
-		result := image.NewRGBA64(image.Rect(0, 0, int(width), int(height)))
+	if p.finish != nil {
+		return p.finish(result), nil
+	}
+	return result, nil
+}
 
-		// horizontal filter, results in transposed temporary image
-		coeffs, offset, filterLength := createWeightsNearest(temp.Bounds().Dy(), taps, blur, scaleX)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(temp, i, cpus).(*image.RGBA64)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestGeneric(img, slice, scaleX, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
-		}
+// bands splits the output rows filtered with the weights w into bands
+// whose temporary images of rowBytes per source row fit into
+// Options.TileMemory, and returns the first row of each band followed by
+// the number of rows. A band has at least one row.
+func (r *Resizer) bands(w *weights, rowBytes int) []int {
+	n := len(w.offset)
+	budget := r.opts.TileMemory
+	if budget <= 0 {
+		return []int{0, n}
+	}
+	bands := []int{0}
+	for y0 := 0; y0 < n; {
+		y1 := y0 + 1
+		for y1 < n && int64(w.offset[y1]+w.filterLength-w.offset[y0])*int64(rowBytes) <= budget {
+			y1++
+		}
+		bands = append(bands, y1)
+		y0 = y1
+	}
+	return bands
+}
 
-		// horizontal filter on transposed image, result is not transposed
-		coeffs, offset, filterLength = createWeightsNearest(result.Bounds().Dy(), taps, blur, scaleY)
-		wg.Add(cpus)
-		panics = makePanicChan(cpus)
-		for i := 0; i < cpus; i++ {
-			slice := makeSlice(result, i, cpus).(*image.RGBA64)
-			go func() {
-				defer recoverfn(&wg, panics)
-				nearestRGBA64(temp, slice, scaleY, coeffs, offset, filterLength)
-			}()
-		}
-		wg.Wait()
-		if err := retrieveErrors(panics); err != nil {
-			return nil, err
+// sourceRows returns the range of the n source rows read with the
+// weights w for the output rows y0 to y1. Samples mirrored at an edge are
+// rows next to it, so the range extends to them and to the edge, which
+// keeps their indices relative to the edge. Samples wrapped around an
+// edge are rows at the other edge, all rows are read then.
+func sourceRows(w *weights, y0, y1, n int, edge Edge) image.Rectangle {
+	first, last := w.offset[y0], w.offset[y1-1]+w.filterLength
+	lo := clampInt(first, 0, n-1)
+	hi := clampInt(last, lo+1, n)
+	switch {
+	case edge == EdgeWrap && (first < 0 || last > n):
+		lo, hi = 0, n
+	case edge == EdgeReflect:
+		if first < 0 {
+			lo, hi = 0, clampInt(-first, hi, n)
+		}
+		if last > n {
+			lo, hi = clampInt(2*n-last, 0, lo), n
 		}
+	}
+	return image.Rect(0, lo, 0, hi)
+}
 
-		return result, nil
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// subRows returns the rows y0 to y1 of img, relative to its bounds.
+func subRows(img image.Image, y0, y1 int) image.Image {
+	b := img.Bounds()
+	rect := image.Rect(b.Min.X, b.Min.Y+y0, b.Max.X, b.Min.Y+y1)
+	if rect == b {
+		return img
+	}
+	if m, ok := img.(imageWithSubImage); ok {
+		return m.SubImage(rect)
 	}
+	return &subImage{img, rect}
+}
+
+// subImage restricts the bounds of an image without SubImage method.
+type subImage struct {
+	image.Image
+	rect image.Rectangle
+}
 
+func (m *subImage) Bounds() image.Rectangle {
+	return m.rect
 }
 
 // Calculates scaling factors using old and new image dimensions.
@@ -599,6 +487,12 @@ func calcFactors(width, height uint, oldWidth, oldHeight float64) (scaleX, scale
 	return
 }
 
+// scaledSize returns the number of pixels size source pixels are scaled
+// to with scale, at least 1.
+func scaledSize(size, scale float64) uint {
+	return uint(math.Max(0.7+size/scale, 1))
+}
+
 type imageWithSubImage interface {
 	image.Image
 	SubImage(image.Rectangle) image.Image
@@ -608,52 +502,109 @@ func makeSlice(img imageWithSubImage, i, n int) image.Image {
 	return img.SubImage(image.Rect(img.Bounds().Min.X, img.Bounds().Min.Y+i*img.Bounds().Dy()/n, img.Bounds().Max.X, img.Bounds().Min.Y+(i+1)*img.Bounds().Dy()/n))
 }
 
-func recoverfn(wg *sync.WaitGroup, panics chan string) {
-	defer wg.Done()
-	if rc := recover(); rc != nil {
-		e, ok := rc.(error)
-		if ok {
-			panics <- e.Error()
-		} else {
-			panics <- "Unknown panic error"
+// Number of rows the output of a pass is split into for the workers.
+// Cancellation is checked before each of them.
+const sliceRows = 16
+
+// Minimum number of output pixels per worker when the number of workers
+// is chosen automatically. Smaller passes don't pay off the overhead.
+const pixelsPerWorker = 128 * 128
+
+// resizePass splits out into row slices and calls fn for each of them,
+// using the workers configured in r's options. Panics in fn are recovered
+// and returned as *PanicError. If ctx is done, the remaining slices are
+// skipped and ctx.Err() is returned.
+func (r *Resizer) resizePass(ctx context.Context, pass Pass, out imageWithSubImage, fn func(slice image.Image)) error {
+	slices := (out.Bounds().Dy() + sliceRows - 1) / sliceRows
+	if slices == 0 {
+		return nil
+	}
+	n := r.opts.Workers
+	if n <= 0 {
+		n = runtime.NumCPU()
+		if max := out.Bounds().Dx()*out.Bounds().Dy()/pixelsPerWorker + 1; n > max {
+			n = max
 		}
 	}
-}
+	if n > slices {
+		n = slices
+	}
 
-func makePanicChan(size int) chan string {
-	return make(chan string, size)
-}
+	var next int32
+	wg := sync.WaitGroup{}
+	errs := makeErrorChan(n)
+	worker := func() {
+		var slice image.Rectangle
+		defer recoverfn(&wg, errs, pass, &slice)
+		for {
+			if err := ctx.Err(); err != nil {
+				errs <- err
+				return
+			}
+			j := int(atomic.AddInt32(&next, 1)) - 1
+			if j >= slices {
+				return
+			}
+			m := makeSlice(out, j, slices)
+			slice = m.Bounds()
+			fn(m)
+		}
+	}
 
-type resizeErrors struct {
-	errs []string
+	// The calling goroutine is one of the workers.
+	wg.Add(n)
+	for i := 1; i < n; i++ {
+		if r.opts.Pool == nil {
+			go worker()
+		} else if !r.opts.Pool.submit(worker) {
+			// The pool is busy, use fewer workers.
+			wg.Done()
+		}
+	}
+	worker()
+	wg.Wait()
+	return retrieveErrors(errs)
 }
 
-func newResizeErrors(max int) *resizeErrors {
-	r := resizeErrors{}
-	r.errs = make([]string, 0, max)
-	return &r
+func recoverfn(wg *sync.WaitGroup, errs chan error, pass Pass, slice *image.Rectangle) {
+	defer wg.Done()
+	if rc := recover(); rc != nil {
+		errs <- &PanicError{Pass: pass, Slice: *slice, Value: rc, Stack: debug.Stack()}
+	}
 }
 
-func (r *resizeErrors) Error() string {
-	return strings.Join(r.errs, ", ")
+func makeErrorChan(size int) chan error {
+	return make(chan error, size)
 }
 
-func retrieveErrors(panics chan string) error {
-	var e *resizeErrors
-	close(panics)
-	max := cap(panics)
+// retrieveErrors collects the errors of a pass. Duplicates, like the
+// cancellation error reported by every worker, are dropped and a single
+// error is returned as is, several ones as Errors.
+func retrieveErrors(errs chan error) error {
+	var e Errors
+	close(errs)
 
-	for str := range panics {
-		if e == nil {
-			e = newResizeErrors(max)
+	for err := range errs {
+		if !containsError(e, err) {
+			e = append(e, err)
 		}
-
-		e.errs = append(e.errs, str)
 	}
 
-	if e == nil {
+	switch len(e) {
+	case 0:
 		return nil
-	} else {
+	case 1:
+		return e[0]
+	default:
 		return e
 	}
 }
+
+func containsError(errs []error, err error) bool {
+	for _, e := range errs {
+		if e == err {
+			return true
+		}
+	}
+	return false
+}
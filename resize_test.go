@@ -1,8 +1,13 @@
 package resize
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"image"
 	"image/color"
+	"math"
+	"reflect"
 	"runtime"
 	"testing"
 )
@@ -44,6 +49,34 @@ func Test_ZeroImg(t *testing.T) {
 	}
 }
 
+func Test_DerivedSizeAtLeastOne(t *testing.T) {
+	// The derived height of 2/29 pixels is at least one pixel.
+	wide := image.NewRGBA(image.Rect(0, 0, 29, 2))
+	m, err := Resize(1, 0, wide, Bilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != image.Rect(0, 0, 1, 1) {
+		t.Errorf("got bounds %v, want %v", m.Bounds(), image.Rect(0, 0, 1, 1))
+	}
+	if _, err := ThumbnailWithOptions(6, 0, wide, Bilinear, nil); err != nil {
+		t.Error(err)
+	}
+	if _, err := ResizeJPEG(1, 0, bytes.NewReader(encodeJPEG(t, 64, 4, false)), Bilinear, nil); err != nil {
+		t.Error(err)
+	}
+
+	// An empty result isn't resized.
+	r := NewResizer(Bilinear, nil)
+	m, err = r.resizeWith(context.Background(), &rgbaPipeline, EdgeClamp, 1, 0, 29, 2, 0, 0, wide, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Bounds().Empty() {
+		t.Errorf("got bounds %v, want an empty image", m.Bounds())
+	}
+}
+
 func Test_CorrectResize(t *testing.T) {
 	zeroImg := image.NewGray16(image.Rect(0, 0, 256, 256))
 
@@ -175,3 +208,376 @@ func Benchmark_LargeJpegThumbLanczos2(b *testing.B) {
 func Benchmark_LargeJpegThumbLanczos3(b *testing.B) {
 	jpegThumb(b, Lanczos3)
 }
+
+func Test_CustomFilter(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 30, 30))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	triangle := &Filter{Support: 1, Kernel: linear}
+
+	want, err := Resize(20, 12, img, Bilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Resize(20, 12, img, triangle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want.(*image.Gray).Pix, got.(*image.Gray).Pix) {
+		t.Error("custom filter output differs from equivalent predefined filter")
+	}
+}
+
+func Test_Kernels(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 40))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	for _, c := range []struct {
+		name   string
+		filter InterpolationFunction
+		taps   int
+	}{
+		{"Gaussian", Gaussian, 4},
+		{"Hermite", Hermite, 2},
+		{"BSpline", BSpline, 4},
+		{"CatmullRom", CatmullRom, 4},
+		{"Hann", Hann, 6},
+		{"Hamming", Hamming, 6},
+		{"Blackman", Blackman, 6},
+		{"Kaiser", Kaiser, 6},
+		{"Lanczos(1)", Lanczos(1), 2},
+		{"Lanczos(5)", Lanczos(5), 10},
+	} {
+		taps, kernel := c.filter.kernel()
+		if taps != c.taps {
+			t.Errorf("%s: got %d taps, want %d", c.name, taps, c.taps)
+		}
+		if k := kernel(c.filter.Support); math.Abs(k) > 1e-2 {
+			t.Errorf("%s: got %v at the support, want 0", c.name, k)
+		}
+		// Interpolating kernels are 1 at 0, the others at least peak there.
+		if k := kernel(0); k > 1 || k < kernel(0.5) {
+			t.Errorf("%s: got %v at 0", c.name, k)
+		}
+		for _, size := range []uint{13, 77} {
+			m, err := Resize(size, size, img, c.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range m.(*image.Gray).Pix {
+				if v != 0x80 {
+					t.Fatalf("%s: got %#x resizing to %d, want %#x", c.name, v, size, 0x80)
+				}
+			}
+		}
+	}
+	if Lanczos(3) != Lanczos3 {
+		t.Error("Lanczos(3) is not Lanczos3")
+	}
+	if f := Lanczos(0); f.Support != 1 {
+		t.Errorf("got support %v for Lanczos(0), want 1", f.Support)
+	}
+	if f := Lanczos(1 << 30); f.Support != MaxLanczosLobes {
+		t.Errorf("got support %v for Lanczos(1 << 30), want %d", f.Support, MaxLanczosLobes)
+	}
+}
+
+func Test_BCCubic(t *testing.T) {
+	for _, c := range []struct {
+		b, c   float64
+		kernel func(float64) float64
+	}{
+		{0, 0.5, cubic},
+		{1, 0, bspline},
+		{1.0 / 3, 1.0 / 3, mitchellnetravali},
+	} {
+		kernel := bcCubic(c.b, c.c)
+		for x := -2.5; x <= 2.5; x += 0.125 {
+			if got, want := kernel(x), c.kernel(x); math.Abs(got-want) > 1e-9 {
+				t.Errorf("B=%v, C=%v: got %v at %v, want %v", c.b, c.c, got, x, want)
+			}
+		}
+	}
+	if BCCubic(0, 0.5) != CatmullRom || BCCubic(robidouxB, robidouxC) != Robidoux {
+		t.Error("the predefined filters are not returned")
+	}
+	if f := BCCubic(0.2, 0.4); f.Support != 2 || math.Abs(f.Kernel(0)-(1-0.2/3)) > 1e-9 {
+		t.Errorf("got support %v and %v at 0", f.Support, f.Kernel(0))
+	}
+}
+
+func Test_Area(t *testing.T) {
+	// Each output pixel is the mean of a 4x4 block.
+	gray := image.NewGray(image.Rect(0, 0, 40, 40))
+	gray16 := image.NewGray16(gray.Rect)
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 7)
+		gray16.SetGray16(i%40, i/40, color.Gray16{uint16(i * 1009)})
+	}
+	m, err := Resize(10, 10, gray, Area)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m16, err := Resize(10, 10, gray16, Area)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			var sum, sum16 int
+			for i := 0; i < 16; i++ {
+				sum += int(gray.GrayAt(4*x+i%4, 4*y+i/4).Y)
+				sum16 += int(gray16.Gray16At(4*x+i%4, 4*y+i/4).Y)
+			}
+			if got, want := int(m.(*image.Gray).GrayAt(x, y).Y), sum/16; got < want-1 || got > want+1 {
+				t.Errorf("got %d at %d,%d, want %d", got, x, y, want)
+			}
+			if got, want := int(m16.(*image.Gray16).Gray16At(x, y).Y), sum16/16; got < want-1 || got > want+1 {
+				t.Errorf("got %d at %d,%d, want %d", got, x, y, want)
+			}
+		}
+	}
+
+	// Partially covered pixels count with their coverage: both halves
+	// of the output get half of the bright pixel in the middle.
+	row := image.NewGray(image.Rect(0, 0, 3, 1))
+	row.Pix[1] = 0xff
+	m, err = Resize(2, 1, row, Area)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.(*image.Gray).Pix; got[0] != 0x55 || got[1] != 0x55 {
+		t.Errorf("got %v, want a third of the bright pixel in each", got)
+	}
+
+	if _, _, filterLength := createWeightsArea8(200, 20, 0); filterLength != 20 {
+		t.Errorf("got filter length %d, want 20", filterLength)
+	}
+
+	// Large upscales give output pixels far smaller than a source pixel.
+	small := image.NewGray(image.Rect(0, 0, 2, 2))
+	small16 := image.NewGray16(small.Rect)
+	for i := range small.Pix {
+		small.Pix[i] = 0x80
+		small16.SetGray16(i%2, i/2, color.Gray16{0x8000})
+	}
+	for _, src := range []image.Image{small, small16} {
+		m, err := Resize(1200, 1200, src, Area)
+		if err != nil {
+			t.Fatalf("%T: %v", src, err)
+		}
+		if got := m.At(600, 600); got != src.At(0, 0) {
+			t.Errorf("%T: got %v, want %v", src, got, src.At(0, 0))
+		}
+	}
+}
+
+func Test_NilFilter(t *testing.T) {
+	m, err := Resize(6, 6, img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != image.Rect(0, 0, 6, 6) {
+		t.Fail()
+	}
+}
+
+func Test_NRGBATransparentEdges(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if x < 20 {
+				img.SetNRGBA(x, y, color.NRGBA{0xff, 0, 0, 0xff})
+			} else {
+				// Invisible green must not bleed into the result.
+				img.SetNRGBA(x, y, color.NRGBA{0, 0xff, 0, 0})
+			}
+		}
+	}
+
+	for _, interp := range []InterpolationFunction{NearestNeighbor, Bilinear, Lanczos3} {
+		m, err := Resize(15, 15, img, interp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, ok := m.(*image.NRGBA)
+		if !ok {
+			t.Fatalf("got %T, want *image.NRGBA", m)
+		}
+		for i := 0; i < len(out.Pix); i += 4 {
+			if out.Pix[i+3] != 0 && (out.Pix[i+1] != 0 || out.Pix[i] < 0xf0) {
+				t.Fatalf("halo at offset %d: %v", i, out.Pix[i:i+4])
+			}
+		}
+	}
+}
+
+func Test_NRGBAUniformIdentity(t *testing.T) {
+	// Every color at every nonzero alpha, one per row.
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 256*255))
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		v, a := uint8(y%256), uint8(1+y/256)
+		for x := 0; x < 4; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, a})
+		}
+	}
+
+	m, err := Resize(3, uint(img.Bounds().Dy()), img, Area)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := m.(*image.NRGBA)
+	for y := 0; y < out.Bounds().Dy(); y++ {
+		want := img.NRGBAAt(0, y)
+		for x := 0; x < 3; x++ {
+			if c := out.NRGBAAt(x, y); c != want {
+				t.Fatalf("(%d,%d): got %v, want %v", x, y, c, want)
+			}
+		}
+	}
+}
+
+func Test_NRGBA64Type(t *testing.T) {
+	img := image.NewNRGBA64(image.Rect(0, 0, 20, 20))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	m, err := Resize(7, 7, img, MitchellNetravali)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, ok := m.(*image.NRGBA64)
+	if !ok {
+		t.Fatalf("got %T, want *image.NRGBA64", m)
+	}
+	if c := out.NRGBA64At(3, 3); c != (color.NRGBA64{0xffff, 0xffff, 0xffff, 0xffff}) {
+		t.Errorf("got %v, want opaque white", c)
+	}
+}
+
+func Test_ResizeContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ResizeContext(ctx, 10, 10, img, Bilinear, nil); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+
+	// Cancel while the first row slice of the horizontal pass is read,
+	// the remaining slices are skipped.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	src := &cancelingImage{Image: image.NewGray(image.Rect(0, 0, 400, 50)), cancel: cancel}
+	if _, err := ResizeContext(ctx, 200, 25, src, Bilinear, &Options{Workers: 1}); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if src.maxX >= 100 {
+		t.Errorf("read up to column %d after canceling, want only the first row slice", src.maxX)
+	}
+}
+
+// cancelingImage calls cancel when its pixels are read and records the
+// rightmost column read.
+type cancelingImage struct {
+	image.Image
+	cancel func()
+	maxX   int
+}
+
+func (m *cancelingImage) At(x, y int) color.Color {
+	m.cancel()
+	if x > m.maxX {
+		m.maxX = x
+	}
+	return m.Image.At(x, y)
+}
+
+func Test_TileMemory(t *testing.T) {
+	rect := image.Rect(0, 0, 73, 101)
+	ycbcr := image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = uint8(i * 7)
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i] = uint8(i * 3)
+		ycbcr.Cr[i] = uint8(i * 5)
+	}
+	nrgba := image.NewNRGBA(rect)
+	gray16 := image.NewGray16(rect)
+	for i := range nrgba.Pix {
+		nrgba.Pix[i] = uint8(i*13 + i/17)
+		if i < len(gray16.Pix) {
+			gray16.Pix[i] = uint8(i * 11)
+		}
+	}
+
+	for _, img := range []image.Image{ycbcr, nrgba, gray16} {
+		for _, interp := range []InterpolationFunction{NearestNeighbor, Lanczos3} {
+			for _, edge := range []Edge{EdgeClamp, EdgeReflect, EdgeWrap, EdgeTransparent} {
+				for _, size := range []uint{17, 150} {
+					want, err := ResizeWithOptions(size, size, img, interp, &Options{Edge: edge})
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, budget := range []int64{1, 3000} {
+						got, err := ResizeWithOptions(size, size, img, interp, &Options{Edge: edge, TileMemory: budget})
+						if err != nil {
+							t.Fatal(err)
+						}
+						if !reflect.DeepEqual(got, want) {
+							t.Errorf("%T to %d with edge %d and budget %d: tiled result differs", img, size, edge, budget)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func Test_TileMemoryEdges(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 20000))
+	r := newResizer(Lanczos3, &Options{TileMemory: 10000})
+	wy := r.weightsFor(8, 10000, 2, 0)
+	bands := r.bands(wy, 100)
+	// Mirrored rows are next to the edges, every band fits the budget.
+	for i := 0; i+1 < len(bands); i++ {
+		if rows := sourceRows(wy, bands[i], bands[i+1], 20000, EdgeReflect); rows.Dy()*100 > 10000 {
+			t.Fatalf("band %d to %d reads %d rows", bands[i], bands[i+1], rows.Dy())
+		}
+	}
+
+	// Wrapped bands read all rows, which the memory limit counts.
+	for _, c := range []struct {
+		edge     Edge
+		exceeded bool
+	}{
+		{EdgeClamp, false},
+		{EdgeReflect, false},
+		{EdgeWrap, true},
+	} {
+		opts := &Options{Edge: c.edge, TileMemory: 10000, Limits: Limits{MaxMemory: 2000000}}
+		_, err := ResizeWithOptions(100, 10000, img, Lanczos3, opts)
+		if exceeded := errors.Is(err, ErrLimitExceeded); exceeded != c.exceeded || err != nil && !exceeded {
+			t.Errorf("edge %d: got error %v", c.edge, err)
+		}
+	}
+}
+
+func Test_Bands(t *testing.T) {
+	w := &weights{offset: []int{-1, 1, 3, 5, 7, 9}, filterLength: 4}
+	for _, c := range []struct {
+		budget int64
+		want   []int
+	}{
+		{0, []int{0, 6}},
+		{1, []int{0, 1, 2, 3, 4, 5, 6}},
+		{12, []int{0, 2, 4, 6}},
+		{1000, []int{0, 6}},
+	} {
+		r := newResizer(Bilinear, &Options{TileMemory: c.budget})
+		if got := r.bands(w, 2); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("budget %d: got bands %v, want %v", c.budget, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// Maximum number of coefficient tables a Resizer keeps. The cache is
+// cleared once it grows beyond that.
+const maxCachedWeights = 64
+
+// A Resizer resizes images using a fixed interpolation function and options.
+// Coefficient tables are cached by source size, target size and filter, and
+// temporary buffers are reused between calls, which reduces allocations when
+// many images of the same size are resized to the same targets.
+// A Resizer is safe for concurrent use by multiple goroutines.
+type Resizer struct {
+	interp InterpolationFunction
+	opts   Options
+
+	mu      sync.Mutex
+	weights map[weightsKey]*weights // nil disables caching
+	buffers *sync.Pool              // nil disables pooling
+}
+
+type weightsKey struct {
+	precision int // 8, 16 or 0 for nearest-neighbor
+	dy        int
+	scale     float64
+	origin    float64
+	filter    *Filter
+}
+
+type weights struct {
+	coeffs8      []int16
+	coeffs16     []int32
+	nearest      []bool
+	offset       []int
+	filterLength int
+	scale        float64
+}
+
+// NewResizer returns a Resizer using the interpolation function interp
+// and the options opts, which may be nil.
+func NewResizer(interp InterpolationFunction, opts *Options) *Resizer {
+	r := newResizer(interp, opts)
+	r.weights = make(map[weightsKey]*weights)
+	r.buffers = new(sync.Pool)
+	return r
+}
+
+// newResizer returns a Resizer that neither caches nor pools.
+func newResizer(interp InterpolationFunction, opts *Options) *Resizer {
+	r := &Resizer{interp: interp}
+	if opts != nil {
+		r.opts = *opts
+	}
+	return r
+}
+
+// Resize scales img to width and height, see the package level Resize.
+func (r *Resizer) Resize(width, height uint, img image.Image) (image.Image, error) {
+	return r.ResizeContext(context.Background(), width, height, img)
+}
+
+// ResizeContext scales img to width and height, see the package level
+// ResizeContext.
+func (r *Resizer) ResizeContext(ctx context.Context, width, height uint, img image.Image) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.opts.validate(); err != nil {
+		return nil, err
+	}
+	if err := r.opts.Limits.checkSource(img.Bounds()); err != nil {
+		return nil, err
+	}
+	if img.Bounds().Empty() {
+		if width == 0 && height == 0 {
+			return img, nil
+		}
+		return nil, ErrEmptyImage
+	}
+	scaleX, scaleY := calcFactors(width, height, float64(img.Bounds().Dx()), float64(img.Bounds().Dy()))
+	if width == 0 {
+		width = scaledSize(float64(img.Bounds().Dx()), scaleX)
+	}
+	if height == 0 {
+		height = scaledSize(float64(img.Bounds().Dy()), scaleY)
+	}
+
+	// Trivial case: return input image, or a sharpened copy of it
+	if int(width) == img.Bounds().Dx() && int(height) == img.Bounds().Dy() {
+		if r.opts.Sharpen != nil {
+			return r.sharpenCopy(ctx, img, r.opts.Sharpen)
+		}
+		return img, nil
+	}
+	if err := checkSize(width, height, img.Bounds()); err != nil {
+		return nil, err
+	}
+	if err := r.checkLimits(width, height, scaleX, scaleY, img); err != nil {
+		return nil, err
+	}
+
+	return r.scale(ctx, width, height, scaleX, scaleY, 0, 0, img, nil)
+}
+
+// ResizeRegion scales the area region of img to width and height, see the
+// package level ResizeRegion.
+func (r *Resizer) ResizeRegion(width, height uint, img image.Image, region Region) (image.Image, error) {
+	if err := r.opts.validate(); err != nil {
+		return nil, err
+	}
+	if err := r.opts.Limits.checkSource(img.Bounds()); err != nil {
+		return nil, err
+	}
+	if img.Bounds().Empty() {
+		return nil, ErrEmptyImage
+	}
+	if !region.valid() {
+		return nil, ErrInvalidRegion
+	}
+	scaleX, scaleY := calcFactors(width, height, region.Dx(), region.Dy())
+	if width == 0 {
+		width = scaledSize(region.Dx(), scaleX)
+	}
+	if height == 0 {
+		height = scaledSize(region.Dy(), scaleY)
+	}
+	if err := checkSize(width, height, img.Bounds()); err != nil {
+		return nil, err
+	}
+	if err := r.checkLimits(width, height, scaleX, scaleY, img); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	x0 := region.X0 - float64(img.Bounds().Min.X)
+	y0 := region.Y0 - float64(img.Bounds().Min.Y)
+	return r.scale(ctx, width, height, scaleX, scaleY, x0, y0, img, nil)
+}
+
+// ResizeInto scales src to the size of dst, see the package level ResizeInto.
+func (r *Resizer) ResizeInto(dst draw.Image, src image.Image) error {
+	if err := r.opts.validate(); err != nil {
+		return err
+	}
+	bounds := dst.Bounds()
+	if bounds.Empty() {
+		return nil
+	}
+	if src.Bounds().Empty() {
+		return ErrEmptyImage
+	}
+	width, height := uint(bounds.Dx()), uint(bounds.Dy())
+	if err := checkSize(width, height, src.Bounds()); err != nil {
+		return err
+	}
+	if err := r.opts.Limits.checkSource(src.Bounds()); err != nil {
+		return err
+	}
+	scaleX, scaleY := calcFactors(width, height, float64(src.Bounds().Dx()), float64(src.Bounds().Dy()))
+	if err := r.checkLimits(width, height, scaleX, scaleY, src); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var m image.Image
+	var err error
+	switch {
+	case int(width) == src.Bounds().Dx() && int(height) == src.Bounds().Dy():
+		m = src
+		if r.opts.Sharpen != nil {
+			m, err = r.sharpenCopy(ctx, src, r.opts.Sharpen)
+		}
+	default:
+		out := rebase(dst)
+		m, err = r.scale(ctx, width, height, scaleX, scaleY, 0, 0, src, out)
+		if err == nil && out != nil && m == out {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	draw.Draw(dst, bounds, m, m.Bounds().Min, draw.Src)
+	return nil
+}
+
+// scale resizes img like resize, with the conversions selected by the
+// options. dst is only used if no conversion is needed.
+func (r *Resizer) scale(ctx context.Context, width, height uint, scaleX, scaleY, x0, y0 float64, img, dst image.Image) (image.Image, error) {
+	if m, ok := img.(*image.Paletted); ok && r.opts.Palette != PaletteGeneric {
+		return r.resizePaletted(ctx, width, height, scaleX, scaleY, x0, y0, m)
+	}
+	if r.opts.Linear {
+		return r.resizeLinear(ctx, width, height, scaleX, scaleY, x0, y0, img)
+	}
+	return r.resize(ctx, width, height, scaleX, scaleY, x0, y0, img, dst)
+}
+
+// rebase returns an image sharing the pixels of m with its bounds
+// translated to the origin, or nil if m has no fast path.
+func rebase(m draw.Image) image.Image {
+	b := m.Bounds()
+	r := image.Rect(0, 0, b.Dx(), b.Dy())
+	switch m := m.(type) {
+	case *image.RGBA:
+		return &image.RGBA{Pix: m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], Stride: m.Stride, Rect: r}
+	case *image.RGBA64:
+		return &image.RGBA64{Pix: m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], Stride: m.Stride, Rect: r}
+	case *image.NRGBA:
+		return &image.NRGBA{Pix: m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], Stride: m.Stride, Rect: r}
+	case *image.NRGBA64:
+		return &image.NRGBA64{Pix: m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], Stride: m.Stride, Rect: r}
+	case *image.Gray:
+		return &image.Gray{Pix: m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], Stride: m.Stride, Rect: r}
+	case *image.Gray16:
+		return &image.Gray16{Pix: m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], Stride: m.Stride, Rect: r}
+	}
+	return nil
+}
+
+// cached returns the coefficient table for key, calling create if it
+// isn't cached yet.
+func (r *Resizer) cached(key weightsKey, create func() *weights) *weights {
+	if r.weights == nil {
+		return create()
+	}
+	r.mu.Lock()
+	w, ok := r.weights[key]
+	r.mu.Unlock()
+	if ok {
+		return w
+	}
+
+	w = create()
+	r.mu.Lock()
+	if len(r.weights) >= maxCachedWeights {
+		r.weights = make(map[weightsKey]*weights)
+	}
+	r.weights[key] = w
+	r.mu.Unlock()
+	return w
+}
+
+// weightsFor returns the coefficient table with the given precision
+// for dy output pixels, sampling the source at origin+scale*(y+0.5).
+func (r *Resizer) weightsFor(precision, dy int, scale, origin float64) *weights {
+	filter := r.interp
+	if precision == 0 {
+		filter = NearestNeighbor
+	}
+	return r.cached(weightsKey{precision, dy, scale, origin, filter}, func() *weights {
+		w := &weights{scale: scale}
+		switch {
+		case precision == 8 && filter == Area:
+			w.coeffs8, w.offset, w.filterLength = createWeightsArea8(dy, scale, origin)
+		case precision == 16 && filter == Area:
+			w.coeffs16, w.offset, w.filterLength = createWeightsArea16(dy, scale, origin)
+		case precision == 8:
+			w.coeffs8, w.offset, w.filterLength = createWeights8(dy, filter, r.opts.blur(), scale, origin)
+		case precision == 16:
+			w.coeffs16, w.offset, w.filterLength = createWeights16(dy, filter, r.opts.blur(), scale, origin)
+		default:
+			taps, _ := filter.kernel()
+			w.nearest, w.offset, w.filterLength = createWeightsNearest(dy, taps, r.opts.blur(), scale, origin)
+		}
+		return w
+	})
+}
+
+// buffer returns a byte slice of length n. Its content is undefined.
+func (r *Resizer) buffer(n int) []uint8 {
+	if r.buffers != nil {
+		if b, ok := r.buffers.Get().(*[]uint8); ok && cap(*b) >= n {
+			return (*b)[:n]
+		}
+	}
+	return make([]uint8, n)
+}
+
+// release returns a buffer obtained from buffer for reuse.
+func (r *Resizer) release(b []uint8) {
+	if r.buffers != nil {
+		r.buffers.Put(&b)
+	}
+}
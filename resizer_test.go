@@ -0,0 +1,148 @@
+package resize
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+	"testing"
+)
+
+func Test_ResizerMatchesResize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 13)
+	}
+	want, err := Resize(20, 15, img, Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewResizer(Lanczos3, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				got, err := r.Resize(20, 15, img)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(want.(*image.RGBA).Pix, got.(*image.RGBA).Pix) {
+					t.Error("Resizer output differs from Resize")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// One table per pass.
+	if len(r.weights) != 2 {
+		t.Errorf("got %d cached tables, want 2", len(r.weights))
+	}
+}
+
+func Benchmark_ResizerReduction(b *testing.B) {
+	largeImg := image.NewRGBA(image.Rect(0, 0, 1000, 1000))
+	r := NewResizer(Bicubic, nil)
+
+	b.ReportAllocs()
+	var m image.Image
+	var err error
+	for i := 0; i < b.N; i++ {
+		m, err = r.Resize(300, 300, largeImg)
+		if err != nil {
+			b.FailNow()
+		}
+	}
+	m.At(0, 0)
+}
+
+func Test_ResizeInto(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 50, 40))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 7)
+	}
+	want, err := Resize(20, 10, src, Bilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range canvas.Pix {
+		canvas.Pix[i] = 0xaa
+	}
+	r := image.Rect(30, 40, 50, 50)
+	if err := ResizeInto(canvas.SubImage(r).(*image.RGBA), src, Bilinear); err != nil {
+		t.Fatal(err)
+	}
+
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			got := canvas.RGBAAt(x, y)
+			if (image.Point{x, y}).In(r) {
+				if w := want.(*image.RGBA).RGBAAt(x-r.Min.X, y-r.Min.Y); got != w {
+					t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, w)
+				}
+			} else if got != (color.RGBA{0xaa, 0xaa, 0xaa, 0xaa}) {
+				t.Fatalf("pixel (%d, %d) outside of destination was modified", x, y)
+			}
+		}
+	}
+}
+
+func Test_ResizeIntoOtherType(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 30, 30))
+	for i := range src.Pix {
+		src.Pix[i] = 0x80
+	}
+	dst := image.NewNRGBA(image.Rect(5, 5, 15, 15))
+	if err := ResizeInto(dst, src, Lanczos3); err != nil {
+		t.Fatal(err)
+	}
+	if c := dst.NRGBAAt(10, 10); c != (color.NRGBA{0x80, 0x80, 0x80, 0xff}) {
+		t.Errorf("got %v", c)
+	}
+}
+
+func Test_Blur(t *testing.T) {
+	// A white band spreads out, less so with a narrow filter.
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		if x := i % 64; x >= 28 && x < 36 {
+			img.Pix[i] = 0xff
+		}
+	}
+	spread := func(blur float64) int {
+		m, err := ResizeWithOptions(16, 16, img, Bilinear, &Options{Blur: blur})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lo, hi := 0xff, 0
+		for _, v := range m.(*image.Gray).Pix {
+			if int(v) < lo {
+				lo = int(v)
+			}
+			if int(v) > hi {
+				hi = int(v)
+			}
+		}
+		return hi - lo
+	}
+	if def, one := spread(0), spread(1); def != one {
+		t.Errorf("got spread %d with the default blur, want %d", def, one)
+	}
+	if sharp, soft := spread(0.25), spread(2); sharp <= soft {
+		t.Errorf("got spread %d with blur 0.25, want more than %d with blur 2", sharp, soft)
+	}
+
+	for _, blur := range []float64{-1, 0.01, 11, math.NaN()} {
+		if _, err := ResizeWithOptions(16, 16, img, Bilinear, &Options{Blur: blur}); err != ErrInvalidBlur {
+			t.Errorf("blur %v: got error %v, want %v", blur, err, ErrInvalidBlur)
+		}
+	}
+}
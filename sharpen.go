@@ -0,0 +1,194 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"context"
+	"image"
+	"math"
+)
+
+// MaxSharpenRadius is the largest Radius of a Sharpen.
+const MaxSharpenRadius = 100
+
+// A Sharpen describes an unsharp mask: the difference between an image
+// and a blurred copy is added to the image, which raises the contrast of
+// edges and fine detail.
+type Sharpen struct {
+	// Amount is the strength, the fraction of the difference that is
+	// added, e.g. 0.5. It must not be negative.
+	Amount float64
+	// Radius is the standard deviation of the Gaussian blur in pixels.
+	// It must be positive and at most MaxSharpenRadius.
+	Radius float64
+	// Threshold is the smallest difference that is sharpened, as a
+	// fraction of the full range from 0 to 1. Higher values leave noise
+	// and smooth areas alone.
+	Threshold float64
+}
+
+func (s *Sharpen) valid() bool {
+	return s.Amount >= 0 && !math.IsInf(s.Amount, 1) &&
+		s.Radius > 0 && s.Radius <= MaxSharpenRadius &&
+		s.Threshold >= 0 && s.Threshold <= 1
+}
+
+// UnsharpMask sharpens img with an unsharp mask of the given amount, radius
+// and threshold, see Sharpen. The result has the type Resize would return
+// for img; YCbCr images are only sharpened in the luma plane.
+func UnsharpMask(img image.Image, amount, radius, threshold float64) (image.Image, error) {
+	s := &Sharpen{amount, radius, threshold}
+	if !s.valid() {
+		return nil, ErrInvalidSharpen
+	}
+	if img.Bounds().Empty() {
+		return img, nil
+	}
+	return newResizer(NearestNeighbor, nil).sharpenCopy(context.Background(), img, s)
+}
+
+// sharpenCopy returns a copy of img with the type a resize returns,
+// sharpened with s.
+func (r *Resizer) sharpenCopy(ctx context.Context, img image.Image, s *Sharpen) (image.Image, error) {
+	// A nearest-neighbor resize to the same size copies img to an image
+	// of the result type, which is then sharpened in place.
+	b := img.Bounds()
+	c := &Resizer{interp: NearestNeighbor, opts: r.opts, buffers: r.buffers}
+	c.opts.Quality, c.opts.Sharpen = QualityBest, nil
+	m, err := c.resize(ctx, uint(b.Dx()), uint(b.Dy()), 1, 1, -0.5, -0.5, img, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.sharpen(ctx, m, s); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// sharpen applies s in place to m, which has one of the types a resize
+// returns.
+func (r *Resizer) sharpen(ctx context.Context, m image.Image, s *Sharpen) error {
+	switch y := m.(type) {
+	case *image.YCbCr:
+		m = plane(y.Y, y.YStride, y.Rect)
+	case *image.NYCbCrA:
+		m = plane(y.Y, y.YStride, y.Rect)
+	}
+	b := m.Bounds()
+
+	// The blurred copy is resized to the same size with a Gaussian
+	// filter, centered on the source pixels.
+	blur := &Resizer{interp: gaussianFilter(s.Radius), opts: r.opts, buffers: r.buffers}
+	blur.opts.Blur, blur.opts.Edge, blur.opts.Quality, blur.opts.Sharpen = 0, EdgeClamp, QualityBest, nil
+	blurred, err := blur.resize(ctx, uint(b.Dx()), uint(b.Dy()), 1, 1, -0.5, -0.5, m, nil)
+	if err != nil {
+		return err
+	}
+
+	threshold8 := int32(math.Round(s.Threshold * 0xff))
+	threshold16 := int32(math.Round(s.Threshold * 0xffff))
+	for y := 0; y < b.Dy(); y++ {
+		switch m := m.(type) {
+		case *image.Gray:
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			unsharp8(m.Pix[i:i+b.Dx()], blurred.(*image.Gray).Pix[y*b.Dx():], 1, false, s.Amount, threshold8)
+		case *image.RGBA:
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			unsharp8(m.Pix[i:i+4*b.Dx()], blurred.(*image.RGBA).Pix[y*4*b.Dx():], 4, true, s.Amount, threshold8)
+		case *image.NRGBA:
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			unsharp8(m.Pix[i:i+4*b.Dx()], blurred.(*image.NRGBA).Pix[y*4*b.Dx():], 4, false, s.Amount, threshold8)
+		case *image.Gray16:
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			unsharp16(m.Pix[i:i+2*b.Dx()], blurred.(*image.Gray16).Pix[y*2*b.Dx():], 1, false, s.Amount, threshold16)
+		case *image.RGBA64:
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			unsharp16(m.Pix[i:i+8*b.Dx()], blurred.(*image.RGBA64).Pix[y*8*b.Dx():], 4, true, s.Amount, threshold16)
+		case *image.NRGBA64:
+			i := m.PixOffset(b.Min.X, b.Min.Y+y)
+			unsharp16(m.Pix[i:i+8*b.Dx()], blurred.(*image.NRGBA64).Pix[y*8*b.Dx():], 4, false, s.Amount, threshold16)
+		}
+	}
+	return nil
+}
+
+// gaussianFilter returns a Gaussian kernel with the standard deviation
+// sigma, cut off at 3 sigma.
+func gaussianFilter(sigma float64) *Filter {
+	support := 3 * sigma
+	return &Filter{support, func(in float64) float64 {
+		if in > -support && in < support {
+			return math.Exp(-in * in / (2 * sigma * sigma))
+		}
+		return 0
+	}}
+}
+
+// unsharp8 sharpens the row of 8-bit pixels pix with the blurred pixels
+// blurred. Pixels with 4 channels have an alpha channel last, which is
+// kept, and premultiplied colors are limited to it.
+func unsharp8(pix, blurred []uint8, channels int, premultiplied bool, amount float64, threshold int32) {
+	colors := channels
+	if channels == 4 {
+		colors = 3
+	}
+	for i := 0; i < len(pix); i += channels {
+		max := int32(0xff)
+		if premultiplied {
+			max = int32(pix[i+3])
+		}
+		for c := i; c < i+colors; c++ {
+			v, d := int32(pix[c]), int32(pix[c])-int32(blurred[c])
+			if d > threshold || -d > threshold {
+				v += int32(math.Round(amount * float64(d)))
+			}
+			if v < 0 {
+				v = 0
+			} else if v > max {
+				v = max
+			}
+			pix[c] = uint8(v)
+		}
+	}
+}
+
+// unsharp16 is unsharp8 for big-endian 16-bit samples.
+func unsharp16(pix, blurred []uint8, channels int, premultiplied bool, amount float64, threshold int32) {
+	colors := channels
+	if channels == 4 {
+		colors = 3
+	}
+	for i := 0; i < len(pix); i += 2 * channels {
+		max := int32(0xffff)
+		if premultiplied {
+			max = int32(pix[i+6])<<8 | int32(pix[i+7])
+		}
+		for c := i; c < i+2*colors; c += 2 {
+			v := int32(pix[c])<<8 | int32(pix[c+1])
+			d := v - (int32(blurred[c])<<8 | int32(blurred[c+1]))
+			if d > threshold || -d > threshold {
+				v += int32(math.Round(amount * float64(d)))
+			}
+			if v < 0 {
+				v = 0
+			} else if v > max {
+				v = max
+			}
+			pix[c], pix[c+1] = uint8(v>>8), uint8(v)
+		}
+	}
+}
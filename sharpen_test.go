@@ -0,0 +1,161 @@
+package resize
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// step returns a gray image, 0x40 in the left and 0xc0 in the right half.
+func step() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 40, 10))
+	for i := range img.Pix {
+		if i%40 < 20 {
+			img.Pix[i] = 0x40
+		} else {
+			img.Pix[i] = 0xc0
+		}
+	}
+	return img
+}
+
+func Test_UnsharpMask(t *testing.T) {
+	img := step()
+	orig := append([]uint8(nil), img.Pix...)
+	m, err := UnsharpMask(img, 1, 1.5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray := m.(*image.Gray)
+	if !bytes.Equal(img.Pix, orig) {
+		t.Error("the source was modified")
+	}
+	// Darker before and brighter after the edge, unchanged further away.
+	for _, c := range []struct {
+		x    int
+		want int
+	}{{0, 0}, {18, -1}, {19, -1}, {20, 1}, {21, 1}, {39, 0}} {
+		d := int(gray.GrayAt(c.x, 5).Y) - int(img.GrayAt(c.x, 5).Y)
+		if d < 0 && c.want >= 0 || d > 0 && c.want <= 0 || d == 0 && c.want != 0 {
+			t.Errorf("x=%d: got a change of %d, want the sign %d", c.x, d, c.want)
+		}
+	}
+
+	// The contrast of the step is below the threshold.
+	m, err = UnsharpMask(img, 1, 1.5, 0.6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.(*image.Gray).Pix, orig) {
+		t.Error("differences below the threshold were sharpened")
+	}
+
+	for _, s := range []Sharpen{{-1, 1, 0}, {1, 0, 0}, {1, MaxSharpenRadius + 1, 0}, {1, math.Inf(1), 0}, {1, 1, 2}} {
+		if _, err := UnsharpMask(img, s.Amount, s.Radius, s.Threshold); err != ErrInvalidSharpen {
+			t.Errorf("%+v: got error %v, want %v", s, err, ErrInvalidSharpen)
+		}
+	}
+}
+
+func Test_UnsharpMaskTypes(t *testing.T) {
+	rect := image.Rect(0, 0, 30, 20)
+	rgba := image.NewRGBA(rect)
+	ycbcr := image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)
+	cmyk := image.NewCMYK(rect)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			c := color.RGBA{0x30, 0x30, 0x30, 0x80}
+			if x >= 15 {
+				c = color.RGBA{0x80, 0x60, 0x40, 0x80}
+			}
+			rgba.SetRGBA(x, y, c)
+			ycbcr.Y[ycbcr.YOffset(x, y)] = c.R
+			cmyk.Set(x, y, c)
+		}
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = uint8(i), uint8(i*3)
+	}
+
+	for _, c := range []struct {
+		img  image.Image
+		want string
+	}{
+		{rgba, "*image.RGBA"},
+		{ycbcr, "*image.YCbCr"},
+		{cmyk, "*image.RGBA64"},
+	} {
+		m, err := UnsharpMask(c.img, 2, 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprintf("%T", m); got != c.want {
+			t.Fatalf("got %s, want %s", got, c.want)
+		}
+		if r, g, b, a := m.At(15, 10).RGBA(); r > a || g > a || b > a {
+			t.Errorf("%T: got %v, want premultiplied colors", c.img, m.At(15, 10))
+		}
+	}
+
+	m, _ := UnsharpMask(ycbcr, 2, 1, 0)
+	out := m.(*image.YCbCr)
+	if !bytes.Equal(out.Cb, ycbcr.Cb) || !bytes.Equal(out.Cr, ycbcr.Cr) {
+		t.Error("the chroma planes were changed")
+	}
+	if out.Y[out.YOffset(15, 10)] <= ycbcr.Y[ycbcr.YOffset(15, 10)] {
+		t.Error("the luma plane was not sharpened")
+	}
+}
+
+func Test_ResizeSharpen(t *testing.T) {
+	img := step()
+	s := &Sharpen{Amount: 0.8, Radius: 1, Threshold: 0.01}
+	m, err := ResizeWithOptions(20, 5, img, Lanczos3, &Options{Sharpen: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resized, err := Resize(20, 5, img, Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := UnsharpMask(resized, s.Amount, s.Radius, s.Threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.(*image.Gray).Pix, want.(*image.Gray).Pix) {
+		t.Error("sharpened resize differs from UnsharpMask of the result")
+	}
+	if bytes.Equal(m.(*image.Gray).Pix, resized.(*image.Gray).Pix) {
+		t.Error("the result was not sharpened")
+	}
+
+	// A resize to the same size sharpens a copy.
+	b := img.Bounds()
+	m, err = ResizeWithOptions(uint(b.Dx()), uint(b.Dy()), img, Lanczos3, &Options{Sharpen: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err = UnsharpMask(img, s.Amount, s.Radius, s.Threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == image.Image(img) || !bytes.Equal(m.(*image.Gray).Pix, want.(*image.Gray).Pix) {
+		t.Error("same size resize differs from UnsharpMask of the source")
+	}
+	into := image.NewGray(b)
+	if err := NewResizer(Lanczos3, &Options{Sharpen: s}).ResizeInto(into, img); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(into.Pix, want.(*image.Gray).Pix) {
+		t.Error("same size ResizeInto differs from UnsharpMask of the source")
+	}
+
+	for _, radius := range []float64{-1, MaxSharpenRadius + 1} {
+		if _, err := ResizeWithOptions(20, 5, img, Lanczos3, &Options{Sharpen: &Sharpen{Radius: radius}}); err != ErrInvalidSharpen {
+			t.Errorf("radius %v: got error %v, want %v", radius, err, ErrInvalidSharpen)
+		}
+	}
+}
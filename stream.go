@@ -0,0 +1,176 @@
+/*
+Copyright (c) 2012, Jan Schlicht <jan.schlicht@gmail.com>
+
+Permission to use, copy, modify, and/or distribute this software for any purpose
+with or without fee is hereby granted, provided that the above copyright notice
+and this permission notice appear in all copies.
+
+THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH
+REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND
+FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT,
+INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS
+OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER
+TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF
+THIS SOFTWARE.
+*/
+
+package resize
+
+import (
+	"context"
+	"image"
+	"image/color"
+)
+
+// A RowReader reads the rows of an image from top to bottom.
+type RowReader interface {
+	// ReadRow reads the next row into row, which holds one row of
+	// pixels laid out like the Pix field of the image type of the stream.
+	ReadRow(row []uint8) error
+}
+
+// A RowWriter writes the rows of an image from top to bottom.
+type RowWriter interface {
+	// WriteRow writes the next row, laid out like ReadRow. row must not be
+	// retained after the call.
+	WriteRow(row []uint8) error
+}
+
+// ResizeStream scales an image read row by row from src to width and
+// height and writes the rows of the result to dst as soon as they are
+// complete, using the interpolation function interp and the options opts,
+// which may be nil. config describes the source image; its ColorModel
+// selects the image type the rows are laid out like and must be one of
+// color.GrayModel, color.Gray16Model, color.RGBAModel, color.RGBA64Model,
+// color.NRGBAModel or color.NRGBA64Model. The result has the same type.
+// If one of the parameters width or height is set to 0, its size will be
+// calculated by preserving the aspect ratio.
+//
+// Only as many filtered rows as the filter spans are kept in memory,
+// which allows resizing images that don't fit into memory. Rows after the
+// last one the filter reads are not read from src. The work isn't split
+// across goroutines, Options.Linear, Palette, Quality and Sharpen have no
+// effect, and EdgeReflect and EdgeWrap return ErrUnsupportedStream as they
+// read rows out of order. Otherwise the output is the same as that of
+// ResizeContext.
+func ResizeStream(ctx context.Context, width, height uint, dst RowWriter, src RowReader, config image.Config, interp InterpolationFunction, opts *Options) error {
+	return newResizer(interp, opts).ResizeStream(ctx, width, height, dst, src, config)
+}
+
+// ResizeStream scales an image read row by row from src to width and
+// height and writes it to dst, see the package level ResizeStream.
+func (r *Resizer) ResizeStream(ctx context.Context, width, height uint, dst RowWriter, src RowReader, config image.Config) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := r.opts.validate(); err != nil {
+		return err
+	}
+	bounds := image.Rect(0, 0, config.Width, config.Height)
+	if err := r.opts.Limits.checkSource(bounds); err != nil {
+		return err
+	}
+	if bounds.Empty() {
+		return ErrEmptyImage
+	}
+	in, row := streamImage(config.ColorModel, image.Rect(0, 0, config.Width, 1))
+	if in == nil || r.opts.Edge == EdgeReflect || r.opts.Edge == EdgeWrap {
+		return ErrUnsupportedStream
+	}
+	scaleX, scaleY := calcFactors(width, height, float64(config.Width), float64(config.Height))
+	if width == 0 {
+		width = scaledSize(float64(config.Width), scaleX)
+	}
+	if height == 0 {
+		height = scaledSize(float64(config.Height), scaleY)
+	}
+	if err := checkSize(width, height, bounds); err != nil {
+		return err
+	}
+	if err := r.opts.Limits.checkOutput(width, height); err != nil {
+		return err
+	}
+
+	p := pipelineFor(in, r.interp == nil || r.interp == NearestNeighbor)
+	edge := r.edge(p)
+	wx := r.weightsFor(p.precision, int(width), scaleX, 0)
+	wy := r.weightsFor(p.precision, int(height), scaleY, 0)
+
+	// The horizontally filtered rows are kept in a ring buffer, indexed
+	// by source row modulo the filter length, and copied to the
+	// transposed window the vertical pass reads for an output row.
+	n := wy.filterLength
+	rowLen := p.bpp * int(width)
+	// The ring buffer and window, and a row of the source and the result.
+	mem := int64(2*n)*int64(rowLen) + int64(len(row)) + int64(len(row)/config.Width)*int64(width)
+	if err := r.opts.Limits.checkMemory(mem); err != nil {
+		return err
+	}
+	ring := make([]uint8, n*rowLen)
+	window := make([]uint8, n*rowLen)
+	out, outRow := streamImage(config.ColorModel, image.Rect(0, 0, int(width), 1))
+
+	next := 0
+	for y := 0; y < int(height); y++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		lo := clampInt(wy.offset[y], 0, config.Height-1)
+		hi := clampInt(wy.offset[y]+n, lo+1, config.Height)
+		for ; next < hi; next++ {
+			if err := src.ReadRow(row); err != nil {
+				return err
+			}
+			temp := p.temp(ring[next%n*rowLen:][:rowLen], image.Rect(0, 0, 1, int(width)), in)
+			p.horizontal(in, temp, wx, edge)
+		}
+
+		k := hi - lo
+		for i := 0; i < k; i++ {
+			filtered := ring[(lo+i)%n*rowLen:][:rowLen]
+			for x := 0; x < int(width); x++ {
+				copy(window[(x*k+i)*p.bpp:][:p.bpp], filtered[x*p.bpp:])
+			}
+		}
+		w := &weights{offset: []int{wy.offset[y] - lo}, filterLength: n, scale: wy.scale}
+		switch {
+		case wy.coeffs8 != nil:
+			w.coeffs8 = wy.coeffs8[y*n : (y+1)*n]
+		case wy.coeffs16 != nil:
+			w.coeffs16 = wy.coeffs16[y*n : (y+1)*n]
+		default:
+			w.nearest = wy.nearest[y*n : (y+1)*n]
+		}
+		p.vertical(p.temp(window[:k*rowLen], image.Rect(0, 0, k, int(width)), in), out, w, edge)
+		if err := dst.WriteRow(outRow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamImage returns a new image of the type streamed with model and its
+// pixels, nil if the model isn't supported.
+func streamImage(model color.Model, rect image.Rectangle) (image.Image, []uint8) {
+	switch model {
+	case color.GrayModel:
+		m := image.NewGray(rect)
+		return m, m.Pix
+	case color.Gray16Model:
+		m := image.NewGray16(rect)
+		return m, m.Pix
+	case color.RGBAModel:
+		m := image.NewRGBA(rect)
+		return m, m.Pix
+	case color.RGBA64Model:
+		m := image.NewRGBA64(rect)
+		return m, m.Pix
+	case color.NRGBAModel:
+		m := image.NewNRGBA(rect)
+		return m, m.Pix
+	case color.NRGBA64Model:
+		m := image.NewNRGBA64(rect)
+		return m, m.Pix
+	}
+	return nil, nil
+}
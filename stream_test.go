@@ -0,0 +1,124 @@
+package resize
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// pixRows reads the rows of pix, which are stride bytes apart.
+type pixRows struct {
+	pix    []uint8
+	stride int
+	read   int
+}
+
+func (p *pixRows) ReadRow(row []uint8) error {
+	copy(row, p.pix[p.read*p.stride:])
+	p.read++
+	return nil
+}
+
+// rowBuffer collects the written rows and the number of source rows read
+// when the first one was written.
+type rowBuffer struct {
+	bytes.Buffer
+	src       *pixRows
+	readFirst int
+}
+
+func (b *rowBuffer) WriteRow(row []uint8) error {
+	if b.Len() == 0 {
+		b.readFirst = b.src.read
+	}
+	b.Write(row)
+	return nil
+}
+
+func pixOf(m image.Image) []uint8 {
+	switch m := m.(type) {
+	case *image.Gray:
+		return m.Pix
+	case *image.Gray16:
+		return m.Pix
+	case *image.RGBA:
+		return m.Pix
+	case *image.RGBA64:
+		return m.Pix
+	case *image.NRGBA:
+		return m.Pix
+	case *image.NRGBA64:
+		return m.Pix
+	}
+	return nil
+}
+
+func Test_ResizeStream(t *testing.T) {
+	rect := image.Rect(0, 0, 67, 91)
+	for _, model := range []color.Model{color.GrayModel, color.Gray16Model, color.RGBAModel, color.RGBA64Model, color.NRGBAModel, color.NRGBA64Model} {
+		img, pix := streamImage(model, rect)
+		for i := range pix {
+			pix[i] = uint8(i*7 + i/13)
+		}
+		config := image.Config{ColorModel: model, Width: 67, Height: 91}
+		for _, c := range []struct {
+			width, height uint
+			interp        InterpolationFunction
+			edge          Edge
+		}{
+			{20, 30, Lanczos3, EdgeClamp},
+			{20, 0, Bilinear, EdgeTransparent},
+			{150, 200, Bicubic, EdgeClamp},
+			{20, 30, NearestNeighbor, EdgeClamp},
+			{150, 30, NearestNeighbor, EdgeClamp},
+		} {
+			opts := &Options{Edge: c.edge}
+			want, err := ResizeWithOptions(c.width, c.height, img, c.interp, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src := &pixRows{pix: pix, stride: len(pix) / rect.Dy()}
+			dst := &rowBuffer{src: src}
+			if err := ResizeStream(context.Background(), c.width, c.height, dst, src, config, c.interp, opts); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(dst.Bytes(), pixOf(want)) {
+				t.Errorf("%T to %dx%d: stream differs from Resize", img, c.width, c.height)
+			}
+			if c.height == 30 && dst.readFirst > 20 {
+				t.Errorf("%T to %dx%d: read %d rows before the first was written", img, c.width, c.height, dst.readFirst)
+			}
+		}
+	}
+}
+
+func Test_ResizeStreamUnsupported(t *testing.T) {
+	src := &pixRows{pix: make([]uint8, 100), stride: 10}
+	dst := &rowBuffer{src: src}
+	config := image.Config{ColorModel: color.GrayModel, Width: 10, Height: 10}
+	if err := ResizeStream(context.Background(), 5, 5, dst, src, config, Bilinear, &Options{Edge: EdgeWrap}); err != ErrUnsupportedStream {
+		t.Errorf("got error %v for EdgeWrap, want %v", err, ErrUnsupportedStream)
+	}
+	config.ColorModel = color.CMYKModel
+	if err := ResizeStream(context.Background(), 5, 5, dst, src, config, Bilinear, nil); err != ErrUnsupportedStream {
+		t.Errorf("got error %v for CMYK, want %v", err, ErrUnsupportedStream)
+	}
+}
+
+func Test_ResizeStreamLimits(t *testing.T) {
+	config := image.Config{ColorModel: color.GrayModel, Width: 10, Height: 10}
+	for _, limits := range []Limits{{MaxOutputPixels: 100}, {MaxMemory: 4000}} {
+		src := &pixRows{pix: make([]uint8, 100), stride: 10}
+		dst := &rowBuffer{src: src}
+		err := ResizeStream(context.Background(), 2000, 2000, dst, src, config, Bilinear, &Options{Limits: limits})
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%+v: got error %v, want %v", limits, err, ErrLimitExceeded)
+		}
+		if dst.Len() != 0 || src.read != 0 {
+			t.Errorf("%+v: read %d rows and wrote %d bytes", limits, src.read, dst.Len())
+		}
+	}
+}
@@ -25,6 +25,25 @@ import (
 // It will return original image, without processing it, if original sizes
 // are already smaller than provided constraints.
 func Thumbnail(maxWidth, maxHeight uint, img image.Image, interp InterpolationFunction) (image.Image, error) {
+	return ThumbnailWithOptions(maxWidth, maxHeight, img, interp, nil)
+}
+
+// ThumbnailWithOptions works like Thumbnail, with the additional behaviour
+// selected by opts. A nil opts is the same as calling Thumbnail.
+func ThumbnailWithOptions(maxWidth, maxHeight uint, img image.Image, interp InterpolationFunction, opts *Options) (image.Image, error) {
+	return newResizer(interp, opts).Thumbnail(maxWidth, maxHeight, img)
+}
+
+// Thumbnail downscales img to fit into maxWidth and maxHeight, see the
+// package level Thumbnail.
+func (r *Resizer) Thumbnail(maxWidth, maxHeight uint, img image.Image) (image.Image, error) {
+	if err := r.opts.validate(); err != nil {
+		return nil, err
+	}
+	if err := r.opts.Limits.checkSource(img.Bounds()); err != nil {
+		return nil, err
+	}
+
 	origBounds := img.Bounds()
 	origWidth := uint(origBounds.Dx())
 	origHeight := uint(origBounds.Dy())
@@ -52,5 +71,5 @@ func Thumbnail(maxWidth, maxHeight uint, img image.Image, interp InterpolationFu
 		newHeight = maxHeight
 	}
 
-	return Resize(newWidth, newHeight, img, interp)
+	return r.Resize(newWidth, newHeight, img)
 }
@@ -102,7 +102,7 @@ func (p *ycc) YCbCr() *image.YCbCr {
 			for x := ycbcr.Rect.Min.X; x < ycbcr.Rect.Max.X; x++ {
 				xx := (x - ycbcr.Rect.Min.X)
 				yi := yy + xx
-				ci := cy + xx/2
+				ci := cy + x/2 - ycbcr.Rect.Min.X/2
 				ycbcr.Y[yi] = p.Pix[off+0]
 				ycbcr.Cb[ci] = p.Pix[off+1]
 				ycbcr.Cr[ci] = p.Pix[off+2]
@@ -116,7 +116,7 @@ func (p *ycc) YCbCr() *image.YCbCr {
 			for x := ycbcr.Rect.Min.X; x < ycbcr.Rect.Max.X; x++ {
 				xx := (x - ycbcr.Rect.Min.X)
 				yi := yy + xx
-				ci := cy + xx/2
+				ci := cy + x/2 - ycbcr.Rect.Min.X/2
 				ycbcr.Y[yi] = p.Pix[off+0]
 				ycbcr.Cb[ci] = p.Pix[off+1]
 				ycbcr.Cr[ci] = p.Pix[off+2]
@@ -137,6 +137,34 @@ func (p *ycc) YCbCr() *image.YCbCr {
 				off += 3
 			}
 		}
+	case image.YCbCrSubsampleRatio411:
+		for y := ycbcr.Rect.Min.Y; y < ycbcr.Rect.Max.Y; y++ {
+			yy := (y - ycbcr.Rect.Min.Y) * ycbcr.YStride
+			cy := (y - ycbcr.Rect.Min.Y) * ycbcr.CStride
+			for x := ycbcr.Rect.Min.X; x < ycbcr.Rect.Max.X; x++ {
+				xx := (x - ycbcr.Rect.Min.X)
+				yi := yy + xx
+				ci := cy + x/4 - ycbcr.Rect.Min.X/4
+				ycbcr.Y[yi] = p.Pix[off+0]
+				ycbcr.Cb[ci] = p.Pix[off+1]
+				ycbcr.Cr[ci] = p.Pix[off+2]
+				off += 3
+			}
+		}
+	case image.YCbCrSubsampleRatio410:
+		for y := ycbcr.Rect.Min.Y; y < ycbcr.Rect.Max.Y; y++ {
+			yy := (y - ycbcr.Rect.Min.Y) * ycbcr.YStride
+			cy := (y/2 - ycbcr.Rect.Min.Y/2) * ycbcr.CStride
+			for x := ycbcr.Rect.Min.X; x < ycbcr.Rect.Max.X; x++ {
+				xx := (x - ycbcr.Rect.Min.X)
+				yi := yy + xx
+				ci := cy + x/4 - ycbcr.Rect.Min.X/4
+				ycbcr.Y[yi] = p.Pix[off+0]
+				ycbcr.Cb[ci] = p.Pix[off+1]
+				ycbcr.Cr[ci] = p.Pix[off+2]
+				off += 3
+			}
+		}
 	default:
 		// Default to 4:4:4 subsampling.
 		for y := ycbcr.Rect.Min.Y; y < ycbcr.Rect.Max.Y; y++ {
@@ -172,7 +200,7 @@ func imageYCbCrToYCC(in *image.YCbCr) *ycc {
 			for x := in.Rect.Min.X; x < in.Rect.Max.X; x++ {
 				xx := (x - in.Rect.Min.X)
 				yi := yy + xx
-				ci := cy + xx/2
+				ci := cy + x/2 - in.Rect.Min.X/2
 				p.Pix[off+0] = in.Y[yi]
 				p.Pix[off+1] = in.Cb[ci]
 				p.Pix[off+2] = in.Cr[ci]
@@ -186,7 +214,7 @@ func imageYCbCrToYCC(in *image.YCbCr) *ycc {
 			for x := in.Rect.Min.X; x < in.Rect.Max.X; x++ {
 				xx := (x - in.Rect.Min.X)
 				yi := yy + xx
-				ci := cy + xx/2
+				ci := cy + x/2 - in.Rect.Min.X/2
 				p.Pix[off+0] = in.Y[yi]
 				p.Pix[off+1] = in.Cb[ci]
 				p.Pix[off+2] = in.Cr[ci]
@@ -207,6 +235,34 @@ func imageYCbCrToYCC(in *image.YCbCr) *ycc {
 				off += 3
 			}
 		}
+	case image.YCbCrSubsampleRatio411:
+		for y := in.Rect.Min.Y; y < in.Rect.Max.Y; y++ {
+			yy := (y - in.Rect.Min.Y) * in.YStride
+			cy := (y - in.Rect.Min.Y) * in.CStride
+			for x := in.Rect.Min.X; x < in.Rect.Max.X; x++ {
+				xx := (x - in.Rect.Min.X)
+				yi := yy + xx
+				ci := cy + x/4 - in.Rect.Min.X/4
+				p.Pix[off+0] = in.Y[yi]
+				p.Pix[off+1] = in.Cb[ci]
+				p.Pix[off+2] = in.Cr[ci]
+				off += 3
+			}
+		}
+	case image.YCbCrSubsampleRatio410:
+		for y := in.Rect.Min.Y; y < in.Rect.Max.Y; y++ {
+			yy := (y - in.Rect.Min.Y) * in.YStride
+			cy := (y/2 - in.Rect.Min.Y/2) * in.CStride
+			for x := in.Rect.Min.X; x < in.Rect.Max.X; x++ {
+				xx := (x - in.Rect.Min.X)
+				yi := yy + xx
+				ci := cy + x/4 - in.Rect.Min.X/4
+				p.Pix[off+0] = in.Y[yi]
+				p.Pix[off+1] = in.Cb[ci]
+				p.Pix[off+2] = in.Cr[ci]
+				off += 3
+			}
+		}
 	default:
 		// Default to 4:4:4 subsampling.
 		for y := in.Rect.Min.Y; y < in.Rect.Max.Y; y++ {
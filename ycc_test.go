@@ -60,6 +60,8 @@ func TestConvertYCbCr(t *testing.T) {
 		image.NewYCbCr(image.Rect(0, 0, 50, 50), image.YCbCrSubsampleRatio422),
 		image.NewYCbCr(image.Rect(0, 0, 50, 50), image.YCbCrSubsampleRatio440),
 		image.NewYCbCr(image.Rect(0, 0, 50, 50), image.YCbCrSubsampleRatio444),
+		image.NewYCbCr(image.Rect(0, 0, 50, 50), image.YCbCrSubsampleRatio411),
+		image.NewYCbCr(image.Rect(0, 0, 50, 50), image.YCbCrSubsampleRatio410),
 	}
 
 	for _, img := range testImage {
@@ -149,6 +151,8 @@ func TestYCbCr(t *testing.T) {
 		image.YCbCrSubsampleRatio422,
 		image.YCbCrSubsampleRatio420,
 		image.YCbCrSubsampleRatio440,
+		image.YCbCrSubsampleRatio411,
+		image.YCbCrSubsampleRatio410,
 	}
 	deltas := []image.Point{
 		image.Pt(0, 0),
@@ -187,6 +191,19 @@ func testYCbCr(t *testing.T, r image.Rectangle, subsampleRatio image.YCbCrSubsam
 
 	m := imageYCbCrToYCC(img)
 
+	// The conversion keeps the colors, with the bounds at the origin.
+	for y := r1.Min.Y; y < r1.Max.Y; y++ {
+		for x := r1.Min.X; x < r1.Max.X; x++ {
+			color0 := img.YCbCrAt(x, y)
+			color1 := m.At(x-r1.Min.X, y-r1.Min.Y).(color.YCbCr)
+			if color0 != color1 {
+				t.Errorf("r=%v, subsampleRatio=%v, delta=%v, x=%d, y=%d, color0=%v, color1=%v",
+					r, subsampleRatio, delta, x, y, color0, color1)
+				return
+			}
+		}
+	}
+
 	// Make various sub-images of m.
 	for y0 := delta.Y + 3; y0 < delta.Y+7; y0++ {
 		for y1 := delta.Y + 8; y1 < delta.Y+13; y1++ {